@@ -0,0 +1,131 @@
+package curl
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedMediaType is returned when no decoder handles the response Content-Type
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// MediaRange is a media type with its quality value in the Accept header
+type MediaRange struct {
+	Type string  // e.g. "application/json", "text/*"
+	Q    float64 // 0 < Q <= 1, 0 means 1
+}
+
+func (m MediaRange) String() string {
+	if m.Q <= 0 || m.Q >= 1 {
+		return m.Type
+	}
+	return m.Type + ";q=" + strconv.FormatFloat(m.Q, 'f', -1, 64)
+}
+
+// AcceptHeader return the Accept header value of ranges, sorted by quality
+func AcceptHeader(ranges ...MediaRange) string {
+	sorted := append([]MediaRange(nil), ranges...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return quality(sorted[i]) > quality(sorted[j])
+	})
+	values := make([]string, len(sorted))
+	for i, m := range sorted {
+		values[i] = m.String()
+	}
+	return strings.Join(values, ", ")
+}
+
+func quality(m MediaRange) float64 {
+	if m.Q <= 0 || m.Q > 1 {
+		return 1
+	}
+	return m.Q
+}
+
+// WithAccept sets the Accept header of the call
+//
+//	r.WithAccept(curl.MediaRange{Type: "application/json"}, curl.MediaRange{Type: "application/xml", Q: 0.5})
+func (r *Request) WithAccept(ranges ...MediaRange) *Request {
+	return r.WithHeader("Accept", AcceptHeader(ranges...))
+}
+
+// Accept sets the Accept header for all the calls
+func Accept(ranges ...MediaRange) Option {
+	return func(r *Request) {
+		r.WithGlobalHeader("Accept", AcceptHeader(ranges...))
+	}
+}
+
+// RegisterDecoder sets the decoder of a media type, or of a suffix as "+cbor",
+// for Response.Decode, the encoding of a registered codec is kept.
+//
+//	curl.RegisterDecoder("application/vnd.api+json", jsonapi.Unmarshal)
+func RegisterDecoder(mediaType string, decode func(data []byte, v interface{}) error) {
+	mediaType = strings.ToLower(mediaType)
+	codecs.Lock()
+	defer codecs.Unlock()
+	c := decoderCodec{Codec: codecs.m[mediaType], mediaType: mediaType, decode: decode}
+	if prev, ok := c.Codec.(decoderCodec); ok {
+		c.Codec = prev.Codec
+	}
+	codecs.m[mediaType] = c
+}
+
+// AcceptDecoders return the media ranges of the registered codecs, for WithAccept
+func AcceptDecoders() []MediaRange {
+	codecs.RLock()
+	types := make([]string, 0, len(codecs.m))
+	for t := range codecs.m {
+		if !strings.HasPrefix(t, "+") {
+			types = append(types, t)
+		}
+	}
+	codecs.RUnlock()
+	sort.Strings(types)
+
+	ranges := make([]MediaRange, len(types))
+	for i, t := range types {
+		ranges[i] = MediaRange{Type: t}
+	}
+	return ranges
+}
+
+// MediaType return the media type of the response, without parameters
+func (resp *Response) MediaType() string {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// Decoder return the decoder of the codec of the response Content-Type, or
+// of its suffix, the error wraps ErrUnsupportedMediaType if there is none.
+func (resp *Response) Decoder() (func(data []byte, v interface{}) error, error) {
+	mediaType := resp.MediaType()
+	if mediaType != "" {
+		if c := CodecFor(mediaType); c != nil {
+			return c.Decode, nil
+		}
+	}
+	if mediaType == "" {
+		mediaType = resp.Header.Get("Content-Type")
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedMediaType, mediaType)
+}
+
+// Decode decodes the Response Body into v by its Content-Type
+func (resp *Response) Decode(v interface{}) error {
+	decode, err := resp.Decoder()
+	if err != nil {
+		return err
+	}
+	b, err := resp.Bytes()
+	if err != nil {
+		return err
+	}
+	return decode(b, v)
+}
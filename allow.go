@@ -0,0 +1,82 @@
+package curl
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Capabilities are the methods and CORS policy returned by an OPTIONS request
+type Capabilities struct {
+	StatusCode       int
+	Methods          []string // Allow
+	AllowOrigin      string   // Access-Control-Allow-Origin
+	AllowMethods     []string // Access-Control-Allow-Methods
+	AllowHeaders     []string // Access-Control-Allow-Headers
+	ExposeHeaders    []string // Access-Control-Expose-Headers
+	AllowCredentials bool     // Access-Control-Allow-Credentials
+	MaxAge           time.Duration
+}
+
+// Allows return whether the method is in Allow or Access-Control-Allow-Methods
+func (c *Capabilities) Allows(method string) bool {
+	for _, methods := range [][]string{c.Methods, c.AllowMethods} {
+		for _, m := range methods {
+			if strings.EqualFold(m, method) || m == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Capabilities issues OPTIONS to url and return the allowed methods and CORS headers
+func (r *Request) Capabilities(url string) (*Capabilities, error) {
+	resp, err := r.Options(url)
+	if err != nil {
+		return nil, err
+	}
+	resp.discard()
+	return newCapabilities(resp.Response), nil
+}
+
+// Preflight sends a CORS preflight request as a browser of origin would do
+// before a cross-origin request with method and headers.
+func (r *Request) Preflight(url, origin, method string, headers ...string) (*Capabilities, error) {
+	r.WithHeader("Origin", origin)
+	r.WithHeader("Access-Control-Request-Method", method)
+	if len(headers) > 0 {
+		r.WithHeader("Access-Control-Request-Headers", strings.Join(headers, ", "))
+	}
+	return r.Capabilities(url)
+}
+
+func newCapabilities(resp *http.Response) *Capabilities {
+	c := &Capabilities{
+		StatusCode:       resp.StatusCode,
+		Methods:          headerList(resp.Header, "Allow"),
+		AllowOrigin:      resp.Header.Get("Access-Control-Allow-Origin"),
+		AllowMethods:     headerList(resp.Header, "Access-Control-Allow-Methods"),
+		AllowHeaders:     headerList(resp.Header, "Access-Control-Allow-Headers"),
+		ExposeHeaders:    headerList(resp.Header, "Access-Control-Expose-Headers"),
+		AllowCredentials: strings.EqualFold(resp.Header.Get("Access-Control-Allow-Credentials"), "true"),
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Access-Control-Max-Age")); err == nil && secs > 0 {
+		c.MaxAge = time.Duration(secs) * time.Second
+	}
+	return c
+}
+
+// headerList return the comma separated values of the header
+func headerList(header http.Header, name string) []string {
+	var list []string
+	for _, v := range header.Values(name) {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
@@ -0,0 +1,29 @@
+package curl
+
+import (
+	"context"
+)
+
+// CallAsync executes the call in a goroutine, the result is sent on the returned
+// channel. Cancelling ctx cancels the call.
+//
+//	users := req.CallAsync(ctx, "GET", "http://example.com/api/users", nil)
+//	groups := req.CallAsync(ctx, "GET", "http://example.com/api/groups", nil)
+//	r1, r2 := <-users, <-groups
+func (r *Request) CallAsync(ctx context.Context, method string, url string, body interface{}) <-chan Result {
+	// the per call options belong to this call
+	c := r.clone()
+	r.reset(emptyPayload)
+
+	ch := make(chan Result, 1)
+	go func() {
+		resp, err := c.CallContext(ctx, method, url, body)
+		ch <- Result{resp, err}
+	}()
+	return ch
+}
+
+// GetAsync is CallAsync with GET method
+func (r *Request) GetAsync(ctx context.Context, url string) <-chan Result {
+	return r.CallAsync(ctx, "GET", url, nil)
+}
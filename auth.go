@@ -1,14 +1,34 @@
 package curl
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
+// ErrUnsupportedAuth is returned for a Request.Auth or ProxyAuth of an unsupported type
+var ErrUnsupportedAuth = errors.New("unsupported auth type")
+
 type authenticator interface {
 	HeaderValue() string
 }
 
+// contextAuthenticator is an authenticator fetching its credentials, e.g. from a SecretProvider
+type contextAuthenticator interface {
+	AuthHeader(ctx context.Context) (string, error)
+}
+
 type BasicAuth struct {
 	Username string
 	Password string
@@ -18,6 +38,15 @@ type TokenAuth struct {
 	Token string
 }
 
+// DigestAuth answers Digest challenges (RFC 7616), it is only sent after
+// the server (401, as Request.Auth) or the proxy (407, as Request.ProxyAuth)
+// responds with a challenge.
+type DigestAuth struct {
+	Username string
+	Password string
+	nc       uint32
+}
+
 func (a *BasicAuth) HeaderValue() string {
 	auth := a.Username + ":" + a.Password
 	return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
@@ -27,21 +56,304 @@ func (a *TokenAuth) HeaderValue() string {
 	return "token " + a.Token
 }
 
-func applyAuth(r *Request) {
+// Authorize return the header value for given Digest challenge
+func (a *DigestAuth) Authorize(req *http.Request, challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "digest ") {
+		return "", errors.New("not a digest challenge: " + challenge)
+	}
+	params := parseAuthParams(challenge[len("digest "):])
+
+	var h func() hash.Hash
+	algorithm := params["algorithm"]
+	switch strings.ToUpper(algorithm) {
+	case "", "MD5":
+		h = md5.New
+	case "SHA-256":
+		h = sha256.New
+	default:
+		return "", errors.New("unsupported digest algorithm: " + algorithm)
+	}
+	hexdigest := func(s string) string {
+		d := h()
+		d.Write([]byte(s))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	uri := req.URL.RequestURI()
+	ha1 := hexdigest(a.Username + ":" + params["realm"] + ":" + a.Password)
+	ha2 := hexdigest(req.Method + ":" + uri)
+
+	// a DigestAuth can be shared by concurrent calls
+	nc := fmt.Sprintf("%08x", atomic.AddUint32(&a.nc, 1))
+	cnonce := make([]byte, 8)
+	rand.Read(cnonce)
+	cn := hex.EncodeToString(cnonce)
+
+	var response string
+	qop := ""
+	for _, q := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+	if qop != "" {
+		response = hexdigest(ha1 + ":" + params["nonce"] + ":" + nc + ":" + cn + ":" + qop + ":" + ha2)
+	} else {
+		response = hexdigest(ha1 + ":" + params["nonce"] + ":" + ha2)
+	}
+
+	v := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		a.Username, params["realm"], params["nonce"], uri, response)
+	if algorithm != "" {
+		v += ", algorithm=" + algorithm
+	}
+	if opaque, ok := params["opaque"]; ok {
+		v += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	if qop != "" {
+		v += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cn)
+	}
+	return v, nil
+}
+
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s != "" {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				val, s = s[1:], ""
+			} else {
+				val, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				val, s = s, ""
+			} else {
+				val, s = s[:end], s[end:]
+			}
+		}
+		params[key] = strings.TrimSpace(val)
+	}
+	return params
+}
+
+func applyAuth(ctx context.Context, r *Request) error {
 	if r.Auth == nil {
-		return
+		return nil
 	}
 
 	if r.Headers == nil {
 		r.Headers = make(map[string]string)
 	}
+	v, err := authHeaderValue(ctx, r.Auth, "request.Auth")
+	if err != nil {
+		return err
+	}
+	// digest and signers set it later
+	if v != "" {
+		r.Headers["Authorization"] = v
+	}
+	return nil
+}
 
-	switch v := r.Auth.(type) {
+func authHeaderValue(ctx context.Context, auth interface{}, name string) (string, error) {
+	switch v := auth.(type) {
+	case *DigestAuth:
+		// sent on challenge only
+		return "", nil
+	case requestSigner:
+		// signed once the request is prepared
+		return "", nil
+	case contextAuthenticator:
+		return v.AuthHeader(ctx)
+	case TokenProvider:
+		return tokenAuthHeader(ctx, v)
 	case authenticator:
-		r.Headers["Authorization"] = v.HeaderValue()
+		return v.HeaderValue(), nil
 	case string:
-		r.Headers["Authorization"] = v
+		return v, nil
 	default:
-		panic(fmt.Errorf("unsupported request.Auth type: %T", v))
+		return "", fmt.Errorf("%w: %s is %T", ErrUnsupportedAuth, name, v)
+	}
+}
+
+// proxyAuthKey is the context key of the proxyCredentials of a call
+type proxyAuthKey struct{}
+
+// proxyCredentials are the Request.ProxyAuth of a call, they are only sent
+// to the proxy: in the CONNECT request of https targets, and in the proxied
+// request of http targets. The CONNECT tunnels are pooled by the transport
+// per proxy and target, so the credentials of a call may not be sent when
+// it reuses a tunnel.
+type proxyCredentials struct {
+	value  string // Proxy-Authorization, empty for a digest
+	digest *DigestAuth
+
+	mu        sync.Mutex
+	challenge string // of a CONNECT 407
+	retried   bool
+}
+
+func newProxyCredentials(ctx context.Context, auth interface{}) (*proxyCredentials, error) {
+	if digest, ok := auth.(*DigestAuth); ok {
+		return &proxyCredentials{digest: digest}, nil
+	}
+	v, err := authHeaderValue(ctx, auth, "request.ProxyAuth")
+	if err != nil {
+		return nil, err
+	}
+	return &proxyCredentials{value: v}, nil
+}
+
+func proxyCredentialsOf(ctx context.Context) *proxyCredentials {
+	creds, _ := ctx.Value(proxyAuthKey{}).(*proxyCredentials)
+	return creds
+}
+
+// connectValue return the Proxy-Authorization of the CONNECT request to target
+func (c *proxyCredentials) connectValue(target string) (string, error) {
+	if c.value != "" || c.digest == nil {
+		return c.value, nil
+	}
+	c.mu.Lock()
+	challenge := c.challenge
+	c.mu.Unlock()
+	if challenge == "" {
+		return "", nil
+	}
+	return c.digest.Authorize(&http.Request{Method: "CONNECT", URL: &url.URL{Opaque: target}}, challenge)
+}
+
+// challenged keeps the digest challenge of a CONNECT 407 for a retry
+func (c *proxyCredentials) challenged(challenges []string) {
+	if c.digest == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, challenge := range challenges {
+		if c.challenge == "" && strings.HasPrefix(strings.ToLower(challenge), "digest ") {
+			c.challenge = challenge
+		}
+	}
+}
+
+// retryConnect return whether the CONNECT failed on a digest challenge not answered yet
+func (c *proxyCredentials) retryConnect() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.challenge == "" || c.retried {
+		return false
+	}
+	c.retried = true
+	return true
+}
+
+// setProxyAuthHooks sends the proxyCredentials of the calls in the CONNECT
+// requests of t, and keeps their digest challenges
+func setProxyAuthHooks(t *http.Transport) {
+	header, getHeader, onResponse := t.ProxyConnectHeader, t.GetProxyConnectHeader, t.OnProxyConnectResponse
+
+	t.GetProxyConnectHeader = func(ctx context.Context, proxyURL *url.URL, target string) (http.Header, error) {
+		h := header
+		if getHeader != nil {
+			var err error
+			if h, err = getHeader(ctx, proxyURL, target); err != nil {
+				return nil, err
+			}
+		}
+		creds := proxyCredentialsOf(ctx)
+		if creds == nil {
+			return h, nil
+		}
+		v, err := creds.connectValue(target)
+		if err != nil || v == "" {
+			return h, err
+		}
+		h = h.Clone()
+		if h == nil {
+			h = make(http.Header)
+		}
+		h.Set("Proxy-Authorization", v)
+		return h, nil
+	}
+
+	t.OnProxyConnectResponse = func(ctx context.Context, proxyURL *url.URL, connectReq *http.Request, connectRes *http.Response) error {
+		if onResponse != nil {
+			if err := onResponse(ctx, proxyURL, connectReq, connectRes); err != nil {
+				return err
+			}
+		}
+		if creds := proxyCredentialsOf(ctx); creds != nil && connectRes.StatusCode == http.StatusProxyAuthRequired {
+			creds.challenged(connectRes.Header["Proxy-Authenticate"])
+		}
+		return nil
+	}
+}
+
+// proxyAuthTransport sends the proxyCredentials of the http calls going
+// through a proxy, they are never sent to the origin server
+type proxyAuthTransport struct {
+	*http.Transport
+}
+
+func (t *proxyAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	creds := proxyCredentialsOf(req.Context())
+	if creds != nil && creds.value != "" && req.URL.Scheme == "http" && t.Proxy != nil {
+		if u, err := t.Proxy(req); err == nil && u != nil {
+			req = req.Clone(req.Context())
+			req.Header.Set("Proxy-Authorization", creds.value)
+		}
+	}
+	return t.Transport.RoundTrip(req)
+}
+
+// proxyAuthRetry return a new request answering the 407 challenge in resp,
+// or nil if the challenge cannot be answered.
+func proxyAuthRetry(req *http.Request, resp *http.Response, r *Request) *http.Request {
+	return digestRetry(req, resp.Header["Proxy-Authenticate"], r.ProxyAuth, "Proxy-Authorization")
+}
+
+// authRetry return a new request answering the 401 challenge in resp,
+// or nil if the challenge cannot be answered.
+func authRetry(req *http.Request, resp *http.Response, r *Request) *http.Request {
+	// the challenge of a redirect target is not answered for the first URL
+	if resp.Request != nil && resp.Request.URL.String() != req.URL.String() {
+		return nil
+	}
+	return digestRetry(req, resp.Header["Www-Authenticate"], r.Auth, "Authorization")
+}
+
+// digestRetry return req rewound with the header answering the first digest
+// challenge, or nil if auth is not a DigestAuth or was already sent
+func digestRetry(req *http.Request, challenges []string, auth interface{}, header string) *http.Request {
+	digest, ok := auth.(*DigestAuth)
+	if !ok || req.Header.Get(header) != "" {
+		return nil
+	}
+
+	for _, challenge := range challenges {
+		value, err := digest.Authorize(req, challenge)
+		if err != nil {
+			continue
+		}
+		retry, err := rewindRequest(req)
+		if err != nil {
+			return nil
+		}
+		retry.Header.Set(header, value)
+		return retry
 	}
+	return nil
 }
@@ -0,0 +1,111 @@
+package curl
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// digestServer answers 200 with the request body to the requests authorized
+// by Mufasa, the example user of RFC 2617
+func digestServer(t *testing.T) *httptest.Server {
+	const realm, nonce = "testrealm@host.com", "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth, ok := req.Header["Authorization"]
+		if !ok {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", qop="auth,auth-int", nonce="%s", opaque="5ccc069c403ebaf9f0171e9517f40e41"`, realm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasPrefix(auth[0], "Digest ") {
+			t.Errorf("Authorization = %q", auth[0])
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		p := parseAuthParams(auth[0][len("Digest "):])
+		ha1 := md5Hex("Mufasa:" + realm + ":Circle Of Life")
+		ha2 := md5Hex(req.Method + ":" + p["uri"])
+		want := md5Hex(ha1 + ":" + nonce + ":" + p["nc"] + ":" + p["cnonce"] + ":" + p["qop"] + ":" + ha2)
+		if p["response"] != want || p["uri"] != req.URL.RequestURI() || p["opaque"] != "5ccc069c403ebaf9f0171e9517f40e41" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		w.Write(body)
+	}))
+}
+
+func TestDigestAuth(t *testing.T) {
+	srv := digestServer(t)
+	defer srv.Close()
+
+	resp, err := NewRequest(nil).
+		WithDigestAuth("Mufasa", "Circle Of Life").
+		Post(srv.URL+"/dir/index.html?a=1", "payload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := resp.Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || body != "payload" {
+		t.Fatalf("got %d %q, want 200 %q", resp.StatusCode, body, "payload")
+	}
+}
+
+func TestDigestAuthWrongPassword(t *testing.T) {
+	srv := digestServer(t)
+	defer srv.Close()
+
+	resp, err := NewRequest(nil).WithDigestAuth("Mufasa", "wrong").Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.discard()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("got %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestDigestAuthNC(t *testing.T) {
+	auth := &DigestAuth{Username: "Mufasa", Password: "Circle Of Life"}
+	req, _ := http.NewRequest("GET", "http://www.example.com/dir/index.html", nil)
+	challenge := `Digest realm="testrealm@host.com", qop="auth", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093"`
+	for _, nc := range []string{"00000001", "00000002"} {
+		v, err := auth.Authorize(req, challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p := parseAuthParams(v[len("Digest "):]); p["nc"] != nc {
+			t.Errorf("nc = %q, want %q", p["nc"], nc)
+		}
+	}
+}
+
+func TestApplyAuthDigestHeader(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = req.Header["Authorization"]
+	}))
+	defer srv.Close()
+
+	resp, err := NewRequest(nil).WithDigestAuth("Mufasa", "Circle Of Life").Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.discard()
+	if got != nil {
+		t.Fatalf("Authorization sent before a challenge: %q", got)
+	}
+}
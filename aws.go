@@ -0,0 +1,182 @@
+package curl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AWSCredentials are the (temporary if SessionToken) credentials of AWSSigV4
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiry          time.Time // zero if the credentials do not expire
+}
+
+// AWSCredentialsProvider return the current credentials
+type AWSCredentialsProvider interface {
+	Credentials(ctx context.Context) (*AWSCredentials, error)
+}
+
+// Credentials return c, static credentials are a provider
+func (c *AWSCredentials) Credentials(ctx context.Context) (*AWSCredentials, error) {
+	return c, nil
+}
+
+// EnvAWSCredentials return the credentials of AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+func EnvAWSCredentials() (*AWSCredentials, error) {
+	c := &AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY is not set")
+	}
+	return c, nil
+}
+
+// IMDSCredentials fetches the temporary credentials of the ECS task role
+// (AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or _FULL_URI), or of the EC2
+// instance role by IMDSv2 session tokens. They are cached and refreshed
+// Leeway before their expiry by a single goroutine.
+type IMDSCredentials struct {
+	Request  *Request      // a new request if nil
+	Endpoint string        // of IMDS, default "http://169.254.169.254"
+	Leeway   time.Duration // default 5m
+
+	mu    sync.Mutex
+	creds *AWSCredentials
+}
+
+func NewIMDSCredentials() *IMDSCredentials {
+	return &IMDSCredentials{}
+}
+
+func (c *IMDSCredentials) Credentials(ctx context.Context) (*AWSCredentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	leeway := c.Leeway
+	if leeway == 0 {
+		leeway = 5 * time.Minute
+	}
+	if c.creds != nil && (c.creds.Expiry.IsZero() || time.Now().Add(leeway).Before(c.creds.Expiry)) {
+		return c.creds, nil
+	}
+
+	creds, err := c.fetch(ctx)
+	if err != nil {
+		// the credentials still valid are used while the metadata service fails
+		if c.creds != nil && time.Now().Before(c.creds.Expiry) {
+			return c.creds, nil
+		}
+		return nil, err
+	}
+	c.creds = creds
+	return creds, nil
+}
+
+func (c *IMDSCredentials) request() *Request {
+	if c.Request != nil {
+		return c.Request.clone()
+	}
+	return NewRequest(nil)
+}
+
+func (c *IMDSCredentials) fetch(ctx context.Context) (*AWSCredentials, error) {
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		return c.fetchECS(ctx, "http://169.254.170.2"+uri)
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		return c.fetchECS(ctx, uri)
+	}
+	return c.fetchEC2(ctx)
+}
+
+func (c *IMDSCredentials) fetchECS(ctx context.Context, uri string) (*AWSCredentials, error) {
+	r := c.request()
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		r.WithHeader("Authorization", token)
+	}
+	return getAWSCredentials(ctx, r, uri)
+}
+
+func (c *IMDSCredentials) fetchEC2(ctx context.Context) (*AWSCredentials, error) {
+	endpoint := strings.TrimSuffix(c.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "http://169.254.169.254"
+	}
+
+	// IMDSv2 session token
+	resp, err := c.request().
+		WithHeader("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600").
+		CallContext(ctx, "PUT", endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := awsText(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	base := endpoint + "/latest/meta-data/iam/security-credentials/"
+	resp, err = c.request().WithHeader("X-Aws-Ec2-Metadata-Token", token).CallContext(ctx, "GET", base, nil)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := awsText(resp)
+	if err != nil {
+		return nil, err
+	}
+	role := strings.TrimSpace(strings.SplitN(roles, "\n", 2)[0])
+	if role == "" {
+		return nil, errors.New("no IAM role attached to the instance")
+	}
+
+	return getAWSCredentials(ctx, c.request().WithHeader("X-Aws-Ec2-Metadata-Token", token), base+role)
+}
+
+// getAWSCredentials decodes the credentials of the EC2 and ECS metadata services
+func getAWSCredentials(ctx context.Context, r *Request, uri string) (*AWSCredentials, error) {
+	resp, err := r.CallContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	text, err := awsText(resp)
+	if err != nil {
+		return nil, err
+	}
+	var v struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return nil, err
+	}
+	if v.AccessKeyID == "" {
+		return nil, errors.New("metadata service returned no credentials")
+	}
+	return &AWSCredentials{
+		AccessKeyID:     v.AccessKeyID,
+		SecretAccessKey: v.SecretAccessKey,
+		SessionToken:    v.Token,
+		Expiry:          v.Expiration,
+	}, nil
+}
+
+func awsText(resp *Response) (string, error) {
+	if !resp.OK() {
+		resp.discard()
+		return "", &StatusError{resp.StatusCode, resp.Status}
+	}
+	return resp.Text()
+}
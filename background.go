@@ -0,0 +1,102 @@
+package curl
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrBackgroundFull is returned when the background queue is full
+	ErrBackgroundFull = errors.New("background queue is full")
+	// ErrBackgroundClosed is returned after Background.Close
+	ErrBackgroundClosed = errors.New("background is closed")
+)
+
+// Background sends fire-and-forget calls (analytics, telemetry, ...) from a
+// bounded queue, Close flushes the queued calls.
+type Background struct {
+	jobs chan backgroundJob
+	wg   sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+type backgroundJob struct {
+	request  *Request
+	method   string
+	url      string
+	body     interface{}
+	callback func(*Response, error)
+}
+
+// NewBackground starts workers goroutines sending up to queueSize queued calls
+func NewBackground(queueSize, workers int) *Background {
+	if workers <= 0 {
+		workers = 1
+	}
+	b := &Background{jobs: make(chan backgroundJob, queueSize)}
+	b.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go b.work()
+	}
+	return b
+}
+
+// Send queues the call and return immediately, callback (optional) is called
+// on completion, the response body is closed after it returns.
+func (b *Background) Send(r *Request, method string, url string, body interface{}, callback func(*Response, error)) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrBackgroundClosed
+	}
+
+	// the per call options belong to this call
+	c := r.clone()
+	r.reset(emptyPayload)
+
+	select {
+	case b.jobs <- backgroundJob{c, method, url, body, callback}:
+		return nil
+	default:
+		return ErrBackgroundFull
+	}
+}
+
+// Close stops accepting calls and waits for the queued calls to be sent,
+// or ctx to be done.
+func (b *Background) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.jobs)
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Background) work() {
+	defer b.wg.Done()
+	for job := range b.jobs {
+		resp, err := job.request.Call(job.method, job.url, job.body)
+		if job.callback != nil {
+			job.callback(resp, err)
+		}
+		if resp != nil {
+			resp.discard()
+		}
+	}
+}
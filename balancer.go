@@ -0,0 +1,236 @@
+package curl
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// Balancer chooses the address to connect among the addresses of a host
+// (DNS records or Resolver targets).
+type Balancer interface {
+	// Pick return one of addrs
+	Pick(addrs []string) string
+	// Connected reports the result and the latency of a dial to addr
+	Connected(addr string, latency time.Duration, err error)
+	// Closed reports a connection to addr is closed
+	Closed(addr string)
+}
+
+// RequestBalancer is a Balancer also told of the requests sent by Request
+// on the connections to an address, from the connection to the end of the
+// response body
+type RequestBalancer interface {
+	Balancer
+	Started(addr string)
+	Done(addr string)
+}
+
+// dialAddrs dials the addresses in the balancer order (or in order), until one succeeds
+func dialAddrs(ctx context.Context, dial dialFunc, network string, addrs []string, balancer Balancer) (net.Conn, error) {
+	var err error
+	for len(addrs) > 0 {
+		addr := addrs[0]
+		if balancer != nil {
+			addr = balancer.Pick(addrs)
+		}
+
+		start := time.Now()
+		var conn net.Conn
+		conn, err = dial(ctx, network, addr)
+		if balancer != nil {
+			balancer.Connected(addr, time.Since(start), err)
+		}
+		if err == nil {
+			if balancer != nil {
+				conn = &balancedConn{Conn: conn, addr: addr, balancer: balancer}
+			}
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		addrs = removeString(addrs, addr)
+	}
+	return nil, err
+}
+
+func removeString(list []string, s string) []string {
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != s {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// balancedConn reports Closed once
+type balancedConn struct {
+	net.Conn
+	addr      string
+	balancer  Balancer
+	closeOnce sync.Once
+}
+
+func (c *balancedConn) ReadFrom(r io.Reader) (int64, error) {
+	return readFrom(c.Conn, r)
+}
+
+func (c *balancedConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.balancer.Closed(c.addr)
+	})
+	return c.Conn.Close()
+}
+
+// balancedConnOf return the balancedConn under conn, nil if none
+func balancedConnOf(conn net.Conn) *balancedConn {
+	for {
+		switch c := conn.(type) {
+		case *balancedConn:
+			return c
+		case *statsConn:
+			conn = c.Conn
+		case *tls.Conn:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+}
+
+// RoundRobinBalancer picks the addresses in turn
+type RoundRobinBalancer struct {
+	mu   sync.Mutex
+	next int
+}
+
+func NewRoundRobinBalancer() *RoundRobinBalancer {
+	return new(RoundRobinBalancer)
+}
+
+func (b *RoundRobinBalancer) Pick(addrs []string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	addr := addrs[b.next%len(addrs)]
+	b.next++
+	return addr
+}
+
+func (b *RoundRobinBalancer) Connected(addr string, latency time.Duration, err error) {}
+
+func (b *RoundRobinBalancer) Closed(addr string) {}
+
+// LeastConnBalancer picks the address with the least requests in flight,
+// then with the least open connections. The requests are only counted when
+// sent by Request, a connection shared by HTTP/2 counts all its streams.
+type LeastConnBalancer struct {
+	mu       sync.Mutex
+	conns    map[string]int
+	requests map[string]int
+}
+
+func NewLeastConnBalancer() *LeastConnBalancer {
+	return &LeastConnBalancer{conns: make(map[string]int), requests: make(map[string]int)}
+}
+
+func (b *LeastConnBalancer) Pick(addrs []string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	picked := addrs[0]
+	for _, addr := range addrs[1:] {
+		if r, p := b.requests[addr], b.requests[picked]; r < p || r == p && b.conns[addr] < b.conns[picked] {
+			picked = addr
+		}
+	}
+	return picked
+}
+
+func (b *LeastConnBalancer) Connected(addr string, latency time.Duration, err error) {
+	if err != nil {
+		return
+	}
+	b.mu.Lock()
+	b.conns[addr]++
+	b.mu.Unlock()
+}
+
+func (b *LeastConnBalancer) Closed(addr string) {
+	b.mu.Lock()
+	decrement(b.conns, addr)
+	b.mu.Unlock()
+}
+
+func (b *LeastConnBalancer) Started(addr string) {
+	b.mu.Lock()
+	b.requests[addr]++
+	b.mu.Unlock()
+}
+
+func (b *LeastConnBalancer) Done(addr string) {
+	b.mu.Lock()
+	decrement(b.requests, addr)
+	b.mu.Unlock()
+}
+
+func decrement(counts map[string]int, key string) {
+	if counts[key]--; counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+// EWMABalancer picks the address with the lowest moving average of the
+// connect latency, failed dials count as a penalty latency.
+type EWMABalancer struct {
+	Decay   float64       // weight of a new sample, default 0.3
+	Penalty time.Duration // latency of a failed dial, default 5s
+
+	mu      sync.Mutex
+	latency map[string]float64
+}
+
+func NewEWMABalancer() *EWMABalancer {
+	return &EWMABalancer{latency: make(map[string]float64)}
+}
+
+func (b *EWMABalancer) Pick(addrs []string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	picked, best := addrs[0], math.MaxFloat64
+	for _, addr := range addrs {
+		// unknown addresses are tried first
+		l, ok := b.latency[addr]
+		if !ok {
+			return addr
+		}
+		if l < best {
+			picked, best = addr, l
+		}
+	}
+	return picked
+}
+
+func (b *EWMABalancer) Connected(addr string, latency time.Duration, err error) {
+	if err != nil {
+		latency = durationOrDefault(b.Penalty, 5*time.Second)
+	}
+	decay := b.Decay
+	if decay == 0 {
+		decay = 0.3
+	}
+
+	b.mu.Lock()
+	if l, ok := b.latency[addr]; ok {
+		b.latency[addr] = decay*float64(latency) + (1-decay)*l
+	} else {
+		b.latency[addr] = float64(latency)
+	}
+	b.mu.Unlock()
+}
+
+func (b *EWMABalancer) Closed(addr string) {}
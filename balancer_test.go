@@ -0,0 +1,64 @@
+package curl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeastConnBalancerPick(t *testing.T) {
+	b := NewLeastConnBalancer()
+	b.Connected("a:80", time.Millisecond, nil)
+	b.Connected("a:80", time.Millisecond, nil)
+	b.Connected("b:80", time.Millisecond, nil)
+	b.Started("b:80")
+
+	if addr := b.Pick([]string{"b:80", "a:80"}); addr != "a:80" {
+		t.Fatalf("picked %s, want the address without requests in flight", addr)
+	}
+	b.Done("b:80")
+	if addr := b.Pick([]string{"a:80", "b:80"}); addr != "b:80" {
+		t.Fatalf("picked %s, want the address with the least connections", addr)
+	}
+}
+
+func TestLeastConnBalancerRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	b := NewLeastConnBalancer()
+	client, err := NewClient(&ConnectionOption{
+		Resolver: ResolverFunc(func(ctx context.Context, name string) ([]Target, error) {
+			return []Target{{Addr: addr, Healthy: true}}, nil
+		}),
+		Balancer: b,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inFlight := func() int {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.requests[addr]
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := NewRequest(client).Get("http://service/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := inFlight(); n != 1 {
+			t.Fatalf("%d requests in flight, want 1", n)
+		}
+		resp.Body.Close()
+		if n := inFlight(); n != 0 {
+			t.Fatalf("%d requests in flight after close, want 0", n)
+		}
+	}
+}
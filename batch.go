@@ -0,0 +1,134 @@
+package curl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Job is a call executed by DoAll
+type Job struct {
+	Request *Request // cloned for the call, a new request if nil
+	Method  string
+	URL     string
+	Body    interface{}
+}
+
+// Result is the outcome of a Job
+type Result struct {
+	Response *Response
+	Err      error
+}
+
+// BatchError aggregates the errors of DoAll, by job index
+type BatchError struct {
+	Errors map[int]error
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, i := range e.indexes() {
+		msgs = append(msgs, fmt.Sprintf("job %d: %v", i, e.Errors[i]))
+	}
+	return fmt.Sprintf("%d of batch jobs failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, i := range e.indexes() {
+		errs = append(errs, e.Errors[i])
+	}
+	return errs
+}
+
+// indexes return the indexes of the failed jobs in order
+func (e *BatchError) indexes() []int {
+	indexes := make([]int, 0, len(e.Errors))
+	for i := range e.Errors {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// DoAll executes the jobs with at most concurrency calls in flight, the results
+// are in the order of jobs and their bodies are buffered. All the jobs are executed, failures are returned in a *BatchError.
+func DoAll(ctx context.Context, jobs []Job, concurrency int) ([]Result, error) {
+	return doAll(ctx, jobs, concurrency, false)
+}
+
+// DoAllFailFast is DoAll, but the first failure cancels the other jobs
+func DoAllFailFast(ctx context.Context, jobs []Job, concurrency int) ([]Result, error) {
+	return doAll(ctx, jobs, concurrency, true)
+}
+
+func doAll(ctx context.Context, jobs []Job, concurrency int, failFast bool) ([]Result, error) {
+	if concurrency <= 0 || concurrency > len(jobs) {
+		concurrency = len(jobs)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// a pool of concurrency workers takes the job indexes
+	results := make([]Result, len(jobs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = doJob(ctx, jobs[i])
+				if results[i].Err != nil && failFast {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	next := 0
+feed:
+	for ; next < len(jobs); next++ {
+		select {
+		case indexes <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	// the jobs not started
+	for i := next; i < len(jobs); i++ {
+		results[i].Err = ctx.Err()
+	}
+
+	batchErr := &BatchError{Errors: make(map[int]error)}
+	for i, result := range results {
+		if result.Err != nil {
+			batchErr.Errors[i] = result.Err
+		}
+	}
+	if len(batchErr.Errors) > 0 {
+		return results, batchErr
+	}
+	return results, nil
+}
+
+// doJob calls job, the body of the response is buffered
+func doJob(ctx context.Context, job Job) Result {
+	r := job.Request
+	if r == nil {
+		r = NewRequest(nil)
+	} else {
+		r = r.clone()
+	}
+	resp, err := r.CallContext(ctx, job.Method, job.URL, job.Body)
+	if err == nil {
+		// the body is read before the batch context is canceled
+		_, err = resp.Bytes()
+	}
+	return Result{resp, err}
+}
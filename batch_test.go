@@ -0,0 +1,57 @@
+package curl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDoAllConcurrency(t *testing.T) {
+	var inFlight, maxInFlight int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		w.Write([]byte(req.URL.RawQuery))
+	}))
+	defer srv.Close()
+
+	jobs := make([]Job, 20)
+	for i := range jobs {
+		jobs[i] = Job{Method: "GET", URL: srv.URL + "/?job=" + string(rune('a'+i))}
+	}
+	results, err := DoAll(context.Background(), jobs, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if body, _ := result.Response.Text(); body != "job="+string(rune('a'+i)) {
+			t.Fatalf("result %d = %q", i, body)
+		}
+	}
+	if maxInFlight > 3 {
+		t.Fatalf("%d calls in flight, want at most 3", maxInFlight)
+	}
+}
+
+func TestBatchErrorOrder(t *testing.T) {
+	err := &BatchError{Errors: map[int]error{
+		7: errors.New("c"),
+		2: errors.New("b"),
+		0: errors.New("a"),
+	}}
+	want := "3 of batch jobs failed: job 0: a; job 2: b; job 7: c"
+	for i := 0; i < 10; i++ {
+		if got := err.Error(); got != want {
+			t.Fatalf("Error() = %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package curl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BenchResult is the report of Bench, the latencies include reading the body
+type BenchResult struct {
+	Requests    int           // completed calls, successful or not
+	Errors      int           // calls without response
+	Duration    time.Duration // elapsed time of the benchmark
+	Throughput  float64       // requests per second
+	BytesRead   int64         // response bodies
+	Min         time.Duration
+	Mean        time.Duration
+	Max         time.Duration
+	P50         time.Duration
+	P90         time.Duration
+	P95         time.Duration
+	P99         time.Duration
+	StatusCodes map[int]int    // responses by status code
+	ErrorKinds  map[string]int // errors by kind (e.g. ErrTimeout) or message
+
+	latencies []time.Duration // sorted
+}
+
+// Percentile return the latency under which p percent (0-100) of the calls completed
+func (b *BenchResult) Percentile(p float64) time.Duration {
+	if len(b.latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(b.latencies))*p/100+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(b.latencies) {
+		i = len(b.latencies) - 1
+	}
+	return b.latencies[i]
+}
+
+func (b *BenchResult) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "requests: %d in %v, %.1f req/s, %d errors\n", b.Requests, b.Duration, b.Throughput, b.Errors)
+	fmt.Fprintf(&sb, "latency: min %v, mean %v, p50 %v, p90 %v, p95 %v, p99 %v, max %v\n",
+		b.Min, b.Mean, b.P50, b.P90, b.P95, b.P99, b.Max)
+
+	codes := make([]int, 0, len(b.StatusCodes))
+	for code := range b.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(&sb, "status %d: %d\n", code, b.StatusCodes[code])
+	}
+
+	kinds := make([]string, 0, len(b.ErrorKinds))
+	for kind := range b.ErrorKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(&sb, "error %s: %d\n", kind, b.ErrorKinds[kind])
+	}
+	return sb.String()
+}
+
+// Bench executes job repeatedly by concurrency workers during duration, or
+// until ctx is canceled, then the error is ctx.Err() with the partial result.
+// The job request is cloned for every call, with its full configuration, so
+// the job body must be reusable (string, []byte, form or JSON value).
+//
+//	result, err := curl.Bench(ctx, curl.Job{Request: req, Method: "GET", URL: url}, 16, 30*time.Second)
+//	fmt.Print(result)
+func Bench(ctx context.Context, job Job, concurrency int, duration time.Duration) (*BenchResult, error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	switch job.Body.(type) {
+	case io.Reader, *Payload, Payload:
+		return nil, errors.New("bench job body must be reusable, not a reader or payload")
+	}
+
+	bctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	result := &BenchResult{
+		StatusCodes: make(map[int]int),
+		ErrorKinds:  make(map[string]int),
+	}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	record := func(latency time.Duration, resp *Response, n int64, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		result.Requests++
+		result.latencies = append(result.latencies, latency)
+		result.BytesRead += n
+		if err != nil {
+			result.Errors++
+			result.ErrorKinds[benchErrorKind(err)]++
+			return
+		}
+		result.StatusCodes[resp.StatusCode]++
+	}
+
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for bctx.Err() == nil {
+				r := job.Request
+				if r == nil {
+					r = NewRequest(nil)
+				} else {
+					r = r.clone()
+				}
+
+				t := time.Now()
+				var n int64
+				resp, err := r.CallContext(bctx, job.Method, job.URL, job.Body)
+				if err == nil {
+					n, err = io.Copy(ioutil.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				// the calls interrupted by the end of the benchmark are not counted
+				if err != nil && bctx.Err() != nil {
+					return
+				}
+				record(time.Since(t), resp, n, err)
+			}
+		}()
+	}
+	wg.Wait()
+	result.Duration = time.Since(start)
+	result.summarize()
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func (b *BenchResult) summarize() {
+	if b.Duration > 0 {
+		b.Throughput = float64(b.Requests) / b.Duration.Seconds()
+	}
+	if len(b.latencies) == 0 {
+		return
+	}
+
+	sort.Slice(b.latencies, func(i, j int) bool {
+		return b.latencies[i] < b.latencies[j]
+	})
+	var total time.Duration
+	for _, latency := range b.latencies {
+		total += latency
+	}
+	b.Min = b.latencies[0]
+	b.Max = b.latencies[len(b.latencies)-1]
+	b.Mean = total / time.Duration(len(b.latencies))
+	b.P50 = b.Percentile(50)
+	b.P90 = b.Percentile(90)
+	b.P95 = b.Percentile(95)
+	b.P99 = b.Percentile(99)
+}
+
+// benchErrorKind return the kind of a RequestError, or the message of the cause
+func benchErrorKind(err error) string {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		if reqErr.Kind != nil {
+			return reqErr.Kind.Error()
+		}
+		err = reqErr.Err
+	}
+	// the message of url.Error repeats the URL
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
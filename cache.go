@@ -0,0 +1,660 @@
+package curl
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry is a cached response
+type CacheEntry struct {
+	StatusCode    int
+	Header        http.Header
+	Body          []byte      // as received, still encoded by Content-Encoding
+	Time          time.Time   // when the response was received or revalidated
+	RequestHeader http.Header // request values of the fields in Vary
+}
+
+// Size return the bytes used by the entry body
+func (e *CacheEntry) Size() int64 {
+	return int64(len(e.Body))
+}
+
+// Cache stores responses for Request.Cache, see MemoryCache and DiskCache
+type Cache interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+	Keys() []string
+}
+
+// CacheEvent is reported to Request.OnCache hooks
+type CacheEvent int
+
+const (
+	// CacheServed is a fresh entry served without contacting the server
+	CacheServed CacheEvent = iota + 1
+	// CacheRevalidated is a stale entry served after a 304 Not Modified
+	CacheRevalidated
+	// CacheStale is a stale entry served by stale-while-revalidate, stale-if-error
+	// or in cache only mode
+	CacheStale
+)
+
+func (e CacheEvent) String() string {
+	switch e {
+	case CacheServed:
+		return "served"
+	case CacheRevalidated:
+		return "revalidated"
+	case CacheStale:
+		return "stale"
+	}
+	return "unknown"
+}
+
+// OnCache adds a hook called with the cache key when an entry is served,
+// revalidated or served stale, evictions are reported by the cache, e.g. MemoryCache.OnEvict.
+func (r *Request) OnCache(hook func(event CacheEvent, key string)) *Request {
+	r.CacheHooks = append(r.CacheHooks, hook)
+	return r
+}
+
+func (r *Request) cacheEvent(event CacheEvent, key string) {
+	for _, hook := range r.CacheHooks {
+		hook(event, key)
+	}
+}
+
+// PurgeCache deletes the entries of cache whose key (the URL by default) matches
+// pattern, "*" matches any characters. It return the number of entries
+// deleted.
+//
+//	curl.PurgeCache(cache, "https://api.example.com/users/*")
+func PurgeCache(cache Cache, pattern string) int {
+	n := 0
+	for _, key := range cache.Keys() {
+		if matchWildcard(pattern, key) {
+			cache.Delete(key)
+			n++
+		}
+	}
+	return n
+}
+
+// matchWildcard matches s against pattern where "*" matches any characters
+func matchWildcard(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+// ErrCacheMiss is returned by cache only requests without cached response
+var ErrCacheMiss = errors.New("no cached response")
+
+// CacheMaxEntrySize is the largest body stored in a cache
+var CacheMaxEntrySize int64 = 16 << 20
+
+// WithCache caches GET responses by the HTTP caching rules (Cache-Control,
+// Expires, ETag and Last-Modified revalidation, Vary), one variant is stored
+// per URL. The cache can be shared between requests, so the responses of
+// requests with Authorization are only stored if the server allows it
+// (public, s-maxage or must-revalidate, RFC 7234 section 3.2).
+func (r *Request) WithCache(cache Cache) *Request {
+	r.Cache = cache
+	return r
+}
+
+// WithCacheOnly answers the calls only from the cache, stale entries
+// included, the calls fail with ErrCacheMiss without cached response.
+// It is the offline mode for air-gapped or flaky networks, a request with
+// "Cache-Control: only-if-cached" is handled the same.
+func (r *Request) WithCacheOnly() *Request {
+	r.CacheOnly = true
+	return r
+}
+
+// WithCacheKey sets the function computing the cache key of the requests,
+// e.g. to ignore signature parameters or to separate tenants:
+//
+//	r.WithCacheKey(curl.CacheKeyHeaders(curl.CacheKeyIgnoreParams("X-Amz-Signature"), "X-Tenant"))
+func (r *Request) WithCacheKey(key func(req *http.Request) string) *Request {
+	r.CacheKey = key
+	return r
+}
+
+func (r *Request) cacheKey(req *http.Request) string {
+	if r.CacheKey != nil {
+		return r.CacheKey(req)
+	}
+	return DefaultCacheKey(req)
+}
+
+// DefaultCacheKey is the URL of the request, the variants are selected by
+// the Vary fields of the cached response
+func DefaultCacheKey(req *http.Request) string {
+	return req.URL.String()
+}
+
+// CacheKeyIgnoreParams return a key function of the URL without the query params
+func CacheKeyIgnoreParams(params ...string) func(req *http.Request) string {
+	return func(req *http.Request) string {
+		u := *req.URL
+		query := u.Query()
+		for _, param := range params {
+			query.Del(param)
+		}
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+}
+
+// CacheKeyHeaders return a key function appending the header values to the key of base
+func CacheKeyHeaders(base func(req *http.Request) string, headers ...string) func(req *http.Request) string {
+	return func(req *http.Request) string {
+		var sb strings.Builder
+		sb.WriteString(base(req))
+		for _, name := range headers {
+			sb.WriteString("\n")
+			sb.WriteString(http.CanonicalHeaderKey(name))
+			sb.WriteString(": ")
+			sb.WriteString(strings.Join(req.Header.Values(name), ","))
+		}
+		return sb.String()
+	}
+}
+
+// FromCache return whether the response was served from the cache
+func (resp *Response) FromCache() bool {
+	return resp.cached
+}
+
+// sendCache answers req from r.Cache, or by send and stores the response
+func (r *Request) sendCache(req *http.Request, stats *callStats, send sendFunc) (*Response, error) {
+	key := r.cacheKey(req)
+	reqCC := parseCacheControl(req.Header)
+
+	if _, onlyIfCached := reqCC["only-if-cached"]; r.CacheOnly || onlyIfCached {
+		return r.cachedResponse(key, req)
+	}
+
+	if req.Method != "GET" {
+		resp, err := send(req, stats)
+		// unsafe methods invalidate the cached resource
+		if err == nil && !idempotentMethods[req.Method] && resp.StatusCode < 400 {
+			r.Cache.Delete(key)
+		}
+		return resp, err
+	}
+
+	// conditional and range requests are handled by the caller
+	if req.Header.Get("Range") != "" || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return send(req, stats)
+	}
+
+	if _, ok := reqCC["no-store"]; ok {
+		return send(req, stats)
+	}
+
+	entry, ok := r.Cache.Get(key)
+	if ok && !entry.matchVary(req) {
+		entry, ok = nil, false
+	}
+	if ok {
+		if _, noCache := reqCC["no-cache"]; !noCache {
+			now := time.Now()
+			if entry.fresh(now) {
+				r.cacheEvent(CacheServed, key)
+				return entry.response(req), nil
+			}
+			if entry.staleWithin(now, entry.cacheControl("stale-while-revalidate")) {
+				r.refreshCache(key, req, entry, send)
+				r.cacheEvent(CacheStale, key)
+				return entry.response(req), nil
+			}
+		}
+		setConditional(req, entry)
+	}
+
+	resp, err := send(req, stats)
+	if ok && (err != nil || serverError(resp.StatusCode)) {
+		staleIfError := entry.cacheControl("stale-if-error")
+		if v := reqCC["stale-if-error"]; v != "" {
+			staleIfError = v
+		}
+		if entry.staleWithin(time.Now(), staleIfError) {
+			if resp != nil {
+				resp.discard()
+			}
+			r.cacheEvent(CacheStale, key)
+			return entry.response(req), nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		updated := r.revalidateCache(key, entry, resp)
+		return updated.response(req), nil
+	}
+
+	if err := r.storeCache(key, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// cachedResponse return the cached response of req, even stale, or ErrCacheMiss
+func (r *Request) cachedResponse(key string, req *http.Request) (*Response, error) {
+	if r.Cache == nil || req.Method != "GET" {
+		return nil, ErrCacheMiss
+	}
+	entry, ok := r.Cache.Get(key)
+	if !ok || !entry.matchVary(req) {
+		return nil, ErrCacheMiss
+	}
+	if entry.fresh(time.Now()) {
+		r.cacheEvent(CacheServed, key)
+	} else {
+		r.cacheEvent(CacheStale, key)
+	}
+	return entry.response(req), nil
+}
+
+type sendFunc func(req *http.Request, stats *callStats) (*Response, error)
+
+// cacheRefreshes are the background revalidations in progress by cache and key
+var cacheRefreshes sync.Map
+
+type cacheRefreshKey struct {
+	cache Cache
+	key   string
+}
+
+// refreshCache revalidates entry in background, detached from the call
+func (r *Request) refreshCache(key string, req *http.Request, entry *CacheEntry, send sendFunc) {
+	refreshKey := cacheRefreshKey{r.Cache, key}
+	if _, running := cacheRefreshes.LoadOrStore(refreshKey, true); running {
+		return
+	}
+
+	refresh := req.Clone(context.WithoutCancel(req.Context()))
+	setConditional(refresh, entry)
+	go func() {
+		defer cacheRefreshes.Delete(refreshKey)
+
+		resp, err := send(refresh, new(callStats))
+		if err != nil {
+			return
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			r.revalidateCache(key, entry, resp)
+			return
+		}
+		if r.storeCache(key, refresh, resp) == nil {
+			resp.Body.Close()
+		}
+	}()
+}
+
+// revalidateCache updates entry by the 304 response and stores it
+func (r *Request) revalidateCache(key string, entry *CacheEntry, resp *Response) *CacheEntry {
+	resp.discard()
+	updated := *entry
+	updated.Header = entry.Header.Clone()
+	for k, vs := range resp.Header {
+		if k != "Content-Length" {
+			updated.Header[k] = vs
+		}
+	}
+	updated.Time = time.Now()
+	r.Cache.Set(key, &updated)
+	r.cacheEvent(CacheRevalidated, key)
+	return &updated
+}
+
+func setConditional(req *http.Request, entry *CacheEntry) {
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+}
+
+func serverError(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// storeCache stores resp if cacheable, the body is buffered and replayed
+func (r *Request) storeCache(key string, req *http.Request, resp *Response) error {
+	if !cacheableResponse(resp) || !cacheableAuth(req, resp) || resp.ContentLength > CacheMaxEntrySize {
+		return nil
+	}
+
+	buf, ok, err := bufferBody(resp, CacheMaxEntrySize)
+	if !ok {
+		return err
+	}
+
+	entry := &CacheEntry{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       buf,
+		Time:       time.Now(),
+	}
+	for _, field := range varyFields(resp.Header) {
+		if entry.RequestHeader == nil {
+			entry.RequestHeader = make(http.Header)
+		}
+		entry.RequestHeader[field] = req.Header[field]
+	}
+	r.Cache.Set(key, entry)
+	return nil
+}
+
+// bufferBody reads the body up to limit bytes to return it, the body is
+// replaced to be read again. It return false if the body is larger.
+func bufferBody(resp *Response, limit int64) ([]byte, bool, error) {
+	buf, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, false, err
+	}
+	if int64(len(buf)) > limit {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), resp.Body), resp.Body}
+		return nil, false, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(buf))
+	return buf, true, nil
+}
+
+func cacheableResponse(resp *Response) bool {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+	default:
+		return false
+	}
+
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	for _, field := range varyFields(resp.Header) {
+		if field == "*" {
+			return false
+		}
+	}
+	// something to compute freshness or to revalidate
+	_, maxAge := cc["max-age"]
+	_, noCache := cc["no-cache"]
+	return maxAge || noCache || resp.Header.Get("Expires") != "" ||
+		resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// cacheableAuth return whether the response of an authenticated request can
+// be served to the other requests of the cache (RFC 7234 section 3.2)
+func cacheableAuth(req *http.Request, resp *Response) bool {
+	if req.Header.Get("Authorization") == "" {
+		return true
+	}
+	cc := parseCacheControl(resp.Header)
+	for _, directive := range []string{"public", "s-maxage", "must-revalidate"} {
+		if _, ok := cc[directive]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fresh return whether the entry can be served without revalidation at now
+func (e *CacheEntry) fresh(now time.Time) bool {
+	return e.age(now) < e.lifetime()
+}
+
+// age is the time since the response was generated
+func (e *CacheEntry) age(now time.Time) time.Duration {
+	age := now.Sub(e.Time)
+	if age < 0 {
+		age = 0
+	}
+	if secs, err := strconv.Atoi(e.Header.Get("Age")); err == nil && secs > 0 {
+		age += time.Duration(secs) * time.Second
+	}
+	return age
+}
+
+// lifetime is the freshness lifetime of the entry
+func (e *CacheEntry) lifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return 0
+	}
+	if v, ok := cc["max-age"]; ok {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	date, err := http.ParseTime(e.Header.Get("Date"))
+	if err != nil {
+		date = e.Time
+	}
+	if v := e.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+		return expires.Sub(date)
+	}
+	// heuristic freshness, 10% of the time since the last modification
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && date.After(lastModified) {
+		return date.Sub(lastModified) / 10
+	}
+	return 0
+}
+
+// staleWithin return whether the stale entry can still be served for the
+// seconds of a stale-while-revalidate or stale-if-error directive.
+func (e *CacheEntry) staleWithin(now time.Time, seconds string) bool {
+	secs, err := strconv.Atoi(seconds)
+	if err != nil || secs <= 0 {
+		return false
+	}
+	cc := parseCacheControl(e.Header)
+	if _, ok := cc["must-revalidate"]; ok {
+		return false
+	}
+	if _, ok := cc["no-cache"]; ok {
+		return false
+	}
+	return e.age(now) < e.lifetime()+time.Duration(secs)*time.Second
+}
+
+// cacheControl return the value of a Cache-Control directive of the entry
+func (e *CacheEntry) cacheControl(directive string) string {
+	return parseCacheControl(e.Header)[directive]
+}
+
+func (e *CacheEntry) matchVary(req *http.Request) bool {
+	for _, field := range varyFields(e.Header) {
+		if field == "*" || strings.Join(req.Header[field], ",") != strings.Join(e.RequestHeader[field], ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// response return a new Response of the entry for req
+func (e *CacheEntry) response(req *http.Request) *Response {
+	header := e.Header.Clone()
+	if age := e.age(time.Now()) / time.Second; age > 0 {
+		header.Set("Age", strconv.FormatInt(int64(age), 10))
+	}
+	return &Response{
+		Response: &http.Response{
+			Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+			StatusCode:    e.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+			ContentLength: int64(len(e.Body)),
+			Request:       req,
+		},
+		cached: true,
+	}
+}
+
+func varyFields(header http.Header) []string {
+	var fields []string
+	for _, v := range header["Vary"] {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, http.CanonicalHeaderKey(field))
+			}
+		}
+	}
+	return fields
+}
+
+// parseCacheControl return the Cache-Control directives with their values
+func parseCacheControl(header http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, v := range header["Cache-Control"] {
+		for _, directive := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return cc
+}
+
+// MemoryCache is a in-memory LRU Cache bounded by the size of the bodies
+type MemoryCache struct {
+	MaxSize int64
+
+	mu         sync.Mutex
+	size       int64
+	lru        *list.List // of *memoryCacheItem, most recently used first
+	entries    map[string]*list.Element
+	evictHooks []func(key string)
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+func NewMemoryCache(maxSize int64) *MemoryCache {
+	return &MemoryCache{
+		MaxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*memoryCacheItem).entry, true
+	}
+	return nil, false
+}
+
+func (c *MemoryCache) Set(key string, entry *CacheEntry) {
+	if entry.Size() > c.MaxSize {
+		c.Delete(key)
+		return
+	}
+
+	c.mu.Lock()
+	c.remove(key)
+	c.entries[key] = c.lru.PushFront(&memoryCacheItem{key, entry})
+	c.size += entry.Size()
+	var evicted []string
+	for c.size > c.MaxSize {
+		oldest := c.lru.Back().Value.(*memoryCacheItem).key
+		c.remove(oldest)
+		evicted = append(evicted, oldest)
+	}
+	hooks := c.evictHooks
+	c.mu.Unlock()
+
+	for _, key := range evicted {
+		for _, hook := range hooks {
+			hook(key)
+		}
+	}
+}
+
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	c.remove(key)
+	c.mu.Unlock()
+}
+
+func (c *MemoryCache) Keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// OnEvict adds a hook called with the key of the entries evicted by size
+func (c *MemoryCache) OnEvict(hook func(key string)) {
+	c.mu.Lock()
+	c.evictHooks = append(c.evictHooks, hook)
+	c.mu.Unlock()
+}
+
+// remove must be called with c.mu held
+func (c *MemoryCache) remove(key string) {
+	if e, ok := c.entries[key]; ok {
+		c.size -= e.Value.(*memoryCacheItem).entry.Size()
+		c.lru.Remove(e)
+		delete(c.entries, key)
+	}
+}
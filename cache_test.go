@@ -0,0 +1,177 @@
+package curl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// cacheServer answers the path with the Cache-Control given by the query
+// and counts the requests reaching it
+func cacheServer(t *testing.T, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if cc := req.URL.Query().Get("cc"); cc != "" {
+			w.Header().Set("Cache-Control", cc)
+		}
+		w.Write([]byte("user " + req.Header.Get("Authorization")))
+	}))
+}
+
+func cachedGet(t *testing.T, r *Request, url string) *Response {
+	resp, err := r.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.discard()
+	return resp
+}
+
+func TestCacheFresh(t *testing.T) {
+	var hits int32
+	srv := cacheServer(t, &hits)
+	defer srv.Close()
+
+	cache := NewMemoryCache(1 << 20)
+	var events []CacheEvent
+	r := NewRequest(nil).WithCache(cache).OnCache(func(event CacheEvent, key string) {
+		events = append(events, event)
+	})
+
+	if resp := cachedGet(t, r, srv.URL+"/?cc=max-age=60"); resp.FromCache() {
+		t.Fatal("first response served from the cache")
+	}
+	if resp := cachedGet(t, r, srv.URL+"/?cc=max-age=60"); !resp.FromCache() {
+		t.Fatal("fresh response not served from the cache")
+	}
+	if hits != 1 {
+		t.Fatalf("server hit %d times, want 1", hits)
+	}
+	if len(events) != 1 || events[0] != CacheServed {
+		t.Fatalf("events = %v", events)
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	var hits int32
+	srv := cacheServer(t, &hits)
+	defer srv.Close()
+
+	cache := NewMemoryCache(1 << 20)
+	var events []CacheEvent
+	r := NewRequest(nil).WithCache(cache).OnCache(func(event CacheEvent, key string) {
+		events = append(events, event)
+	})
+
+	cachedGet(t, r, srv.URL+"/?cc=no-cache")
+	resp, err := r.Get(srv.URL + "/?cc=no-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := resp.Text()
+	if hits != 2 || resp.StatusCode != http.StatusOK || body != "user " {
+		t.Fatalf("hits %d, got %d %q", hits, resp.StatusCode, body)
+	}
+	if !resp.FromCache() || len(events) != 1 || events[0] != CacheRevalidated {
+		t.Fatalf("events = %v", events)
+	}
+}
+
+func TestCacheAuthorization(t *testing.T) {
+	var hits int32
+	srv := cacheServer(t, &hits)
+	defer srv.Close()
+
+	cache := NewMemoryCache(1 << 20)
+	// the headers are reset after each call
+	as := func(user string) *Request {
+		return NewRequest(nil).WithCache(cache).WithHeader("Authorization", user)
+	}
+
+	// private by default
+	cachedGet(t, as("alice"), srv.URL+"/private?cc=max-age=60")
+	resp, err := as("bob").Get(srv.URL + "/private?cc=max-age=60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := resp.Text(); resp.FromCache() || body != "user bob" {
+		t.Fatalf("response of alice served to bob: %q", body)
+	}
+
+	// shared if the server allows it
+	cachedGet(t, as("alice"), srv.URL+"/public?cc=public,max-age=60")
+	if resp := cachedGet(t, as("bob"), srv.URL+"/public?cc=public,max-age=60"); !resp.FromCache() {
+		t.Fatal("public response not served from the cache")
+	}
+}
+
+func TestCacheKeyHeaders(t *testing.T) {
+	var hits int32
+	srv := cacheServer(t, &hits)
+	defer srv.Close()
+
+	cache := NewMemoryCache(1 << 20)
+	key := CacheKeyHeaders(DefaultCacheKey, "Authorization")
+	// the headers are reset after each call
+	as := func(user string) *Request {
+		return NewRequest(nil).WithCache(cache).WithCacheKey(key).WithHeader("Authorization", user)
+	}
+
+	cachedGet(t, as("alice"), srv.URL+"/?cc=public,max-age=60")
+	cachedGet(t, as("bob"), srv.URL+"/?cc=public,max-age=60")
+	if resp := cachedGet(t, as("alice"), srv.URL+"/?cc=public,max-age=60"); !resp.FromCache() {
+		t.Fatal("response not served from the cache")
+	}
+	if hits != 2 || len(cache.Keys()) != 2 {
+		t.Fatalf("hits %d, keys %q", hits, cache.Keys())
+	}
+}
+
+func TestCacheOnly(t *testing.T) {
+	var hits int32
+	srv := cacheServer(t, &hits)
+	defer srv.Close()
+
+	cache := NewMemoryCache(1 << 20)
+	if _, err := NewRequest(nil).WithCache(cache).WithCacheOnly().Get(srv.URL); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("err = %v, want ErrCacheMiss", err)
+	}
+	cachedGet(t, NewRequest(nil).WithCache(cache), srv.URL+"/?cc=no-cache")
+	if resp := cachedGet(t, NewRequest(nil).WithCache(cache).WithCacheOnly(), srv.URL+"/?cc=no-cache"); !resp.FromCache() {
+		t.Fatal("stale response not served in cache only mode")
+	}
+	if hits != 1 {
+		t.Fatalf("server hit %d times, want 1", hits)
+	}
+}
+
+func TestDiskCachePersisted(t *testing.T) {
+	var hits int32
+	srv := cacheServer(t, &hits)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cache, err := NewDiskCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedGet(t, NewRequest(nil).WithCache(cache), srv.URL+"/?cc=max-age=60")
+
+	reopened, err := NewDiskCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := cachedGet(t, NewRequest(nil).WithCache(reopened), srv.URL+"/?cc=max-age=60"); !resp.FromCache() {
+		t.Fatal("response not served from the reopened cache")
+	}
+	if hits != 1 {
+		t.Fatalf("server hit %d times, want 1", hits)
+	}
+}
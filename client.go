@@ -1,23 +1,80 @@
 package curl
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/proxy"
 )
 
+// connection pool defaults, used when the ConnectionOption field is 0
+var (
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 32
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
 type ConnectionOption struct {
-	RequestTimeout      time.Duration
-	DialTimeout         time.Duration
-	DialKeepAlive       time.Duration
-	TLSHandshakeTimeout time.Duration
-	InsecureSkipVerify  bool
-	ProxyURL            string
-	DisableRedirect     bool
+	RequestTimeout       time.Duration
+	DialTimeout          time.Duration
+	DialKeepAlive        time.Duration
+	TLSHandshakeTimeout  time.Duration
+	InsecureSkipVerify   bool
+	ProxyURL             string
+	ProxyUsername        string
+	ProxyPassword        string
+	ProxyFromEnvironment bool
+	DisableRedirect      bool
+	MaxRedirects         int
+	UnixSocket           string
+	DialContext          func(ctx context.Context, network, addr string) (net.Conn, error)
+	DialControl          func(network, address string, c syscall.RawConn) error
+	DisableTCPNoDelay    bool
+	LocalAddr            string
+	LocalInterface       string
+	DNSResolver          DNSResolver
+	IPFamily             IPFamily
+	FallbackDelay        time.Duration
+	SRVTargets           map[string]*SRVTarget
+	Resolver             Resolver
+	ResolverRefresh      time.Duration
+	Balancer             Balancer
+	TLSConfig            *tls.Config
+	ClientCertFile       string
+	ClientKeyFile        string
+	ClientCertPEM        []byte
+	ClientKeyPEM         []byte
+	CAFile               string
+	CADir                string
+	CAPEM                []byte
+	CAAppendSystem       bool
+	PinnedPublicKeys     map[string][]string
+	PinnedCertificates   map[string][]string
+	InsecureHosts        []string
+	VerifyCertificate    func(host string, certs []*x509.Certificate) error
+	TLSKeyLogWriter      io.Writer
+	TLSMinVersion        uint16
+	TLSMaxVersion        uint16
+	TLSCipherSuites      []uint16
+	TLSCurvePreferences  []tls.CurveID
+	HTTP2                bool // offer HTTP/2 by ALPN, HTTP/1.1 only by default
+	DisableHTTP2         bool // HTTP/1.1 only, even if HTTP2 is set
+	H2C                  bool // HTTP/2 with prior knowledge
+	HTTP3                bool
+	MaxIdleConns         int
+	MaxIdleConnsPerHost  int
+	MaxConnsPerHost      int
+	IdleConnTimeout      time.Duration
+	ConnStats            *ConnStats
+	DenyPlaintext        bool     // refuse http:// except to the loopback and PlaintextHosts, enforced by Request
+	PlaintextHosts       []string // e.g. "*.internal.example.com"
 }
 
 func NewClient(option *ConnectionOption) (*http.Client, error) {
@@ -25,12 +82,17 @@ func NewClient(option *ConnectionOption) (*http.Client, error) {
 		return new(http.Client), nil
 	}
 
-	transport := newTransport(option)
+	transport, err := newTransport(option)
+	if err != nil {
+		return nil, err
+	}
 	if option.ProxyURL != "" {
-		err := setProxyTransport(transport, option.ProxyURL)
+		err := setProxyTransport(transport, option)
 		if err != nil {
 			return nil, err
 		}
+	} else if option.ProxyFromEnvironment {
+		transport.Proxy = http.ProxyFromEnvironment
 	}
 
 	client := &http.Client{
@@ -38,42 +100,102 @@ func NewClient(option *ConnectionOption) (*http.Client, error) {
 		Transport: transport,
 	}
 
+	// HTTP/3 (QUIC) for https, build with -tags http3
+	if option.HTTP3 {
+		if client.Transport, err = newHTTP3Transport(transport); err != nil {
+			return nil, err
+		}
+	}
+
 	if option.DisableRedirect {
 		client.CheckRedirect = disableRedirect
+	} else {
+		client.CheckRedirect = checkRedirect(intOrDefault(option.MaxRedirects, 10))
+	}
+	if option.DenyPlaintext {
+		setPlaintextPolicy(client, option.PlaintextHosts)
 	}
 
 	return client, nil
 }
 
-func newTransport(option *ConnectionOption) *http.Transport {
+func newTransport(option *ConnectionOption) (*http.Transport, error) {
+	dial, err := newDialContext(option)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := newTLSConfig(option)
+	if err != nil {
+		return nil, err
+	}
+
 	return &http.Transport{
-		Dial: (&net.Dialer{
-			Timeout:   option.DialTimeout,
-			KeepAlive: option.DialKeepAlive,
-		}).Dial,
+		DialContext:         dial,
 		TLSHandshakeTimeout: option.TLSHandshakeTimeout,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: option.InsecureSkipVerify,
-		},
+		TLSClientConfig:     tlsConfig,
+		Protocols:           newProtocols(option),
+		MaxIdleConns:        intOrDefault(option.MaxIdleConns, DefaultMaxIdleConns),
+		MaxIdleConnsPerHost: intOrDefault(option.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost),
+		MaxConnsPerHost:     option.MaxConnsPerHost,
+		IdleConnTimeout:     durationOrDefault(option.IdleConnTimeout, DefaultIdleConnTimeout),
+	}, nil
+}
+
+func intOrDefault(v, def int) int {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+func durationOrDefault(v, def time.Duration) time.Duration {
+	if v == 0 {
+		return def
 	}
+	return v
 }
 
-func setProxyTransport(transport *http.Transport, proxyURL string) error {
-	u, err := url.Parse(proxyURL)
+func newProtocols(option *ConnectionOption) *http.Protocols {
+	protocols := new(http.Protocols)
+	switch {
+	case option.H2C:
+		// HTTP/2 with prior knowledge, also for http:// urls
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+	case option.HTTP2 && !option.DisableHTTP2:
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+	default:
+		// like a http.Transport with a custom dialer and TLS config
+		protocols.SetHTTP1(true)
+	}
+	return protocols
+}
+
+func setProxyTransport(transport *http.Transport, option *ConnectionOption) error {
+	u, err := url.Parse(option.ProxyURL)
 	if err != nil {
 		return err
 	}
 
+	// proxy credentials, sent preemptively as basic auth (also for CONNECT)
+	if option.ProxyUsername != "" {
+		u.User = url.UserPassword(option.ProxyUsername, option.ProxyPassword)
+	}
+
 	switch u.Scheme {
 	case "http", "https":
 		transport.Proxy = http.ProxyURL(u)
 	case "socks5":
-		dialer, err := proxy.FromURL(u, proxy.Direct)
+		dialer, err := proxy.FromURL(u, dialFunc(transport.DialContext))
 		if err != nil {
 			return err
 		}
 		transport.Proxy = http.ProxyFromEnvironment
-		transport.Dial = dialer.Dial
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.Dial(network, addr)
+		}
 	}
 	return nil
 }
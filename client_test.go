@@ -0,0 +1,37 @@
+package curl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewClientProtocols(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		option ConnectionOption
+		proto  string
+	}{
+		{ConnectionOption{InsecureSkipVerify: true}, "HTTP/1.1"},
+		{ConnectionOption{InsecureSkipVerify: true, HTTP2: true}, "HTTP/2.0"},
+		{ConnectionOption{InsecureSkipVerify: true, HTTP2: true, DisableHTTP2: true}, "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		client, err := NewClient(&tt.option)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.Proto != tt.proto {
+			t.Errorf("HTTP2 %v, DisableHTTP2 %v: proto = %s, want %s", tt.option.HTTP2, tt.option.DisableHTTP2, resp.Proto, tt.proto)
+		}
+	}
+}
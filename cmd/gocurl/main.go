@@ -0,0 +1,280 @@
+// Command gocurl is a curl-like client built on github.com/subchen/go-curl.
+//
+//	gocurl https://httpbin.org/get
+//	gocurl -json '{"name":"go"}' https://httpbin.org/post
+//	gocurl -F name=go -F file=@README.md https://httpbin.org/post
+//	gocurl -retry 3 -o go.tar.gz https://example.com/go.tar.gz
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/subchen/go-curl"
+)
+
+// stringsFlag is a repeatable flag
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+var (
+	method     = flag.String("X", "", "request method, default GET or POST with a body")
+	headers    stringsFlag
+	data       = flag.String("d", "", "request body, @file reads it from a file")
+	jsonData   = flag.String("json", "", "JSON request body, @file reads it from a file")
+	forms      stringsFlag
+	output     = flag.String("o", "", "write the body to the file instead of stdout")
+	remoteName = flag.Bool("O", false, "write the body to the file named like the remote file")
+	include    = flag.Bool("i", false, "include the response headers in the output")
+	head       = flag.Bool("I", false, "send a HEAD request and show the response headers")
+	user       = flag.String("u", "", "basic auth as user:password")
+	userAgent  = flag.String("A", "", "User-Agent header")
+	proxyURL   = flag.String("x", "", "proxy URL, http://, https:// or socks5://")
+	proxyUser  = flag.String("U", "", "proxy auth as user:password")
+	insecure   = flag.Bool("k", false, "skip the TLS certificate verification")
+	timeout    = flag.Duration("m", 0, "timeout of the whole request, e.g. 30s")
+	retries    = flag.Int("retry", 0, "retries of the failed idempotent requests")
+	maxRedirs  = flag.Int("max-redirs", 10, "maximum redirects followed, 0 to disable redirects")
+	fail       = flag.Bool("f", false, "exit with code 22 on HTTP errors, without output")
+	verbose    = flag.Bool("v", false, "show the request and response headers on stderr")
+	version    = flag.Bool("version", false, "show the version")
+)
+
+func init() {
+	flag.Var(&headers, "H", "request header as \"Name: value\", repeatable")
+	flag.Var(&forms, "F", "multipart form field as name=value or name=@file, repeatable")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gocurl [options] url\n\n")
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Println("gocurl", curl.Version)
+		return
+	}
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	code, err := run(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gocurl:", err)
+	}
+	os.Exit(code)
+}
+
+func run(rawurl string) (int, error) {
+	client, err := curl.NewClient(&curl.ConnectionOption{
+		InsecureSkipVerify:   *insecure,
+		ProxyURL:             *proxyURL,
+		ProxyUsername:        before(*proxyUser, ":"),
+		ProxyPassword:        after(*proxyUser, ":"),
+		ProxyFromEnvironment: *proxyURL == "",
+		DisableRedirect:      *maxRedirs == 0,
+		MaxRedirects:         *maxRedirs,
+	})
+	if err != nil {
+		return 1, err
+	}
+
+	req := curl.NewRequest(client)
+	if *retries > 0 {
+		req.WithRetry(*retries + 1)
+	}
+	if *userAgent != "" {
+		req.WithUserAgent(*userAgent)
+	}
+	if *user != "" {
+		req.WithBasicAuth(before(*user, ":"), after(*user, ":"))
+	}
+
+	body, err := newBody(req)
+	if err != nil {
+		return 1, err
+	}
+	for _, h := range headers {
+		name, value := before(h, ":"), strings.TrimSpace(after(h, ":"))
+		if name == "" {
+			return 2, fmt.Errorf("invalid header: %q", h)
+		}
+		req.AddHeader(name, value)
+	}
+
+	m := strings.ToUpper(*method)
+	switch {
+	case m != "":
+	case *head:
+		m = "HEAD"
+	case body != nil:
+		m = "POST"
+	default:
+		m = "GET"
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	resp, err := req.CallContext(ctx, m, rawurl, body)
+	if err != nil {
+		return 1, err
+	}
+	defer resp.Body.Close()
+
+	if *verbose {
+		printRequest(resp)
+	}
+	if *fail && resp.StatusCode >= 400 {
+		return 22, fmt.Errorf("the requested URL returned error: %s", resp.Status)
+	}
+
+	out, err := openOutput(rawurl)
+	if err != nil {
+		return 1, err
+	}
+	defer out.Close()
+
+	if *include || *head || *verbose {
+		// the verbose output on stderr is redacted
+		if *verbose && !*include && !*head {
+			printHeaders(os.Stderr, resp.Proto, resp.Status, curl.DefaultRedaction.Header(resp.Header))
+		} else {
+			printHeaders(out, resp.Proto, resp.Status, resp.Header)
+		}
+	}
+	if *head {
+		return 0, nil
+	}
+	if _, err := resp.WriteTo(out); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// newBody return the body of -d, -json or -F, setting the headers of req
+func newBody(req *curl.Request) (interface{}, error) {
+	switch {
+	case *jsonData != "":
+		b, err := readData(*jsonData)
+		if err != nil {
+			return nil, err
+		}
+		req.WithHeader("Content-Type", "application/json; charset=utf-8")
+		req.WithHeader("Accept", "application/json")
+		return b, nil
+	case *data != "":
+		b, err := readData(*data)
+		if err != nil {
+			return nil, err
+		}
+		req.WithHeader("Content-Type", "application/x-www-form-urlencoded")
+		return b, nil
+	case len(forms) > 0:
+		var files []curl.UploadFile
+		values := url.Values{}
+		for _, f := range forms {
+			name, value := before(f, "="), after(f, "=")
+			if strings.HasPrefix(value, "@") {
+				files = append(files, curl.UploadFile{Fieldname: name, Filename: value[1:]})
+			} else {
+				values.Add(name, value)
+			}
+		}
+		return curl.NewMultipartPayload(files, values)
+	}
+	return nil, nil
+}
+
+// readData return the value, or the content of the file for "@file"
+func readData(value string) ([]byte, error) {
+	if strings.HasPrefix(value, "@") {
+		if value == "@-" {
+			return ioutil.ReadAll(os.Stdin)
+		}
+		return ioutil.ReadFile(value[1:])
+	}
+	return []byte(value), nil
+}
+
+// openOutput return the file of -o or -O, or stdout
+func openOutput(rawurl string) (io.WriteCloser, error) {
+	filename := *output
+	if *remoteName && filename == "" {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, err
+		}
+		filename = path.Base(u.Path)
+		if filename == "/" || filename == "." {
+			return nil, fmt.Errorf("remote file name has no length: %s", rawurl)
+		}
+	}
+	if filename == "" || filename == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(filename)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func printRequest(resp *curl.Response) {
+	req := resp.Request
+	fmt.Fprintf(os.Stderr, "> %s %s %s\n", req.Method, req.URL.RequestURI(), req.Proto)
+	fmt.Fprintf(os.Stderr, "> Host: %s\n", req.URL.Host)
+	for name, values := range curl.DefaultRedaction.Header(req.Header) {
+		for _, v := range values {
+			fmt.Fprintf(os.Stderr, "> %s: %s\n", name, v)
+		}
+	}
+	fmt.Fprintln(os.Stderr, ">")
+}
+
+func printHeaders(w io.Writer, proto, status string, header http.Header) {
+	fmt.Fprintf(w, "%s %s\n", proto, status)
+	header.Write(w)
+	fmt.Fprintln(w)
+}
+
+// before return s before sep, or s if sep is not found
+func before(s, sep string) string {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// after return s after sep, or "" if sep is not found
+func after(s, sep string) string {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[i+len(sep):]
+	}
+	return ""
+}
@@ -0,0 +1,149 @@
+package curl
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+)
+
+// Codec encodes the request bodies and decodes the response bodies of a
+// media type, RegisterCodec adds a format for both
+type Codec interface {
+	ContentType() string // e.g. "application/json; charset=utf-8"
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte, v interface{}) error
+}
+
+// JSONCodec and XMLCodec are the default codecs
+var (
+	JSONCodec Codec = jsonCodec{}
+	XMLCodec  Codec = xmlCodec{}
+)
+
+// codecs is the registry of the codecs of the media types, or of the
+// "+suffix", used by the payloads and by the response decoding. The "+suffix"
+// codecs handle the structured syntax suffixes of the media types without
+// their own codec, e.g. "application/problem+json".
+var codecs = struct {
+	sync.RWMutex
+	m map[string]Codec
+}{m: map[string]Codec{
+	"application/json": JSONCodec,
+	"application/xml":  XMLCodec,
+	"text/xml":         XMLCodec,
+	"+json":            JSONCodec,
+	"+xml":             XMLCodec,
+}}
+
+// RegisterCodec registers c for its media type, for NewCodecPayload and for
+// Response.Decode, it replaces the codec of the media type.
+//
+//	curl.RegisterCodec(msgpackCodec{})
+//	payload, err := curl.NewCodecPayload("application/msgpack", v)
+func RegisterCodec(c Codec) {
+	codecs.Lock()
+	codecs.m[codecMediaType(c.ContentType())] = c
+	codecs.Unlock()
+}
+
+// CodecFor return the codec of a media type, or of its suffix, nil if there is none
+func CodecFor(mediaType string) Codec {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	// a bare media type is found without parsing it
+	if c, ok := codecs.m[mediaType]; ok {
+		return c
+	}
+	mediaType = codecMediaType(mediaType)
+	if c, ok := codecs.m[mediaType]; ok {
+		return c
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i > 0 {
+		return codecs.m[mediaType[i:]]
+	}
+	return nil
+}
+
+// decoderCodec is the codec of a decoder set by RegisterDecoder, the
+// encoding is done by the codec it replaces, if any
+type decoderCodec struct {
+	Codec
+	mediaType string
+	decode    func(data []byte, v interface{}) error
+}
+
+func (c decoderCodec) ContentType() string {
+	if c.Codec == nil {
+		return c.mediaType
+	}
+	return c.Codec.ContentType()
+}
+
+func (c decoderCodec) Encode(v interface{}) ([]byte, error) {
+	if c.Codec == nil {
+		return nil, fmt.Errorf("%w: no encoder for %q", ErrUnsupportedMediaType, c.mediaType)
+	}
+	return c.Codec.Encode(v)
+}
+
+func (c decoderCodec) Decode(data []byte, v interface{}) error {
+	return c.decode(data, v)
+}
+
+// NewCodecPayload return a payload of v encoded by the codec of mediaType,
+// e.g. "application/vnd.api+json" is encoded by the JSON codec
+func NewCodecPayload(mediaType string, v interface{}) (*Payload, error) {
+	c := CodecFor(mediaType)
+	if c == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedMediaType, mediaType)
+	}
+	body, err := c.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	contentType := c.ContentType()
+	// a vendor media type keeps its own name
+	if !strings.HasPrefix(mediaType, "+") && codecMediaType(mediaType) != codecMediaType(contentType) {
+		contentType = mediaType
+	}
+	return NewBytesPayload(body).WithContentType(contentType), nil
+}
+
+// codecMediaType return the lower case media type, without parameters
+func codecMediaType(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (jsonCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Decode(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return "application/xml; charset=utf-8"
+}
+
+func (xmlCodec) Encode(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Decode(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
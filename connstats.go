@@ -0,0 +1,236 @@
+package curl
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnStats collects connection pool statistics of a client.
+//
+//	stats := curl.NewConnStats()
+//	client, _ := curl.NewClient(&curl.ConnectionOption{ConnStats: stats})
+type ConnStats struct {
+	dials  int64
+	closed int64
+	inUse  int64
+
+	mu        sync.Mutex
+	lastDials int64
+	lastTime  time.Time
+}
+
+// ConnStatsSnapshot is the pool state at a time
+type ConnStatsSnapshot struct {
+	Dials          int64
+	Open           int64
+	InUse          int64
+	Idle           int64
+	DialsPerSecond float64
+}
+
+func NewConnStats() *ConnStats {
+	return &ConnStats{lastTime: time.Now()}
+}
+
+// Snapshot return the current stats, DialsPerSecond is measured since the previous snapshot
+func (s *ConnStats) Snapshot() ConnStatsSnapshot {
+	dials := atomic.LoadInt64(&s.dials)
+	open := dials - atomic.LoadInt64(&s.closed)
+	inUse := atomic.LoadInt64(&s.inUse)
+
+	s.mu.Lock()
+	now := time.Now()
+	rate := 0.0
+	if elapsed := now.Sub(s.lastTime).Seconds(); elapsed > 0 {
+		rate = float64(dials-s.lastDials) / elapsed
+	}
+	s.lastDials, s.lastTime = dials, now
+	s.mu.Unlock()
+
+	idle := open - inUse
+	if idle < 0 {
+		idle = 0
+	}
+	return ConnStatsSnapshot{
+		Dials:          dials,
+		Open:           open,
+		InUse:          inUse,
+		Idle:           idle,
+		DialsPerSecond: rate,
+	}
+}
+
+// statsDialContext wraps the connections to count bytes, and to collect stats if not nil
+func statsDialContext(dial dialFunc, stats *ConnStats) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if stats != nil {
+			atomic.AddInt64(&stats.dials, 1)
+		}
+		return &statsConn{Conn: conn, stats: stats}, nil
+	}
+}
+
+// statsConn counts the bytes on the wire, and the connection as closed once
+type statsConn struct {
+	net.Conn
+	stats     *ConnStats
+	read      int64
+	written   int64
+	closeOnce sync.Once
+}
+
+func (c *statsConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddInt64(&c.read, int64(n))
+	return n, err
+}
+
+func (c *statsConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(&c.written, int64(n))
+	return n, err
+}
+
+func (c *statsConn) ReadFrom(r io.Reader) (int64, error) {
+	n, err := readFrom(c.Conn, r)
+	atomic.AddInt64(&c.written, n)
+	return n, err
+}
+
+func (c *statsConn) Close() error {
+	c.closeOnce.Do(func() {
+		if c.stats != nil {
+			atomic.AddInt64(&c.stats.closed, 1)
+		}
+	})
+	return c.Conn.Close()
+}
+
+// connTracker records the connection used by a request, and the bytes
+// transferred on it while used by the request. The counts are only valid for
+// HTTP/1.x, an HTTP/2 connection is shared by concurrent requests.
+type connTracker struct {
+	mu       sync.Mutex
+	conn     *statsConn
+	balanced *balancedConn // reported to a RequestBalancer
+	reused   bool
+	wrote    bool
+	counted  bool // the connection counts its bytes
+
+	read0, written0 int64
+	read, written   int64
+}
+
+func (t *connTracker) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			conn := info.Conn
+			if tc, ok := conn.(*tls.Conn); ok {
+				conn = tc.NetConn()
+			}
+
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.release()
+			t.reused = info.Reused
+			t.counted = false
+			if bc := balancedConnOf(conn); bc != nil {
+				if b, ok := bc.balancer.(RequestBalancer); ok {
+					b.Started(bc.addr)
+					t.balanced = bc
+				}
+			}
+			if sc, ok := conn.(*statsConn); ok {
+				if sc.stats != nil {
+					atomic.AddInt64(&sc.stats.inUse, 1)
+				}
+				t.conn = sc
+				t.counted = true
+				// the handshake of a new connection is counted for its first request
+				t.read0, t.written0 = 0, 0
+				if info.Reused {
+					t.read0, t.written0 = atomic.LoadInt64(&sc.read), atomic.LoadInt64(&sc.written)
+				}
+			}
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			t.wrote = true
+			t.mu.Unlock()
+		},
+	}
+}
+
+// wroteRequest return whether the request was (maybe partially) sent
+func (t *connTracker) wroteRequest() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.wrote
+}
+
+// release must be called with t.mu held
+func (t *connTracker) release() {
+	if t.balanced != nil {
+		t.balanced.balancer.(RequestBalancer).Done(t.balanced.addr)
+		t.balanced = nil
+	}
+	if t.conn != nil {
+		t.read, t.written = t.counts()
+		if t.conn.stats != nil {
+			atomic.AddInt64(&t.conn.stats.inUse, -1)
+		}
+		t.conn = nil
+	}
+}
+
+// counts must be called with t.mu held
+func (t *connTracker) counts() (int64, int64) {
+	if t.conn == nil {
+		return t.read, t.written
+	}
+	return atomic.LoadInt64(&t.conn.read) - t.read0, atomic.LoadInt64(&t.conn.written) - t.written0
+}
+
+// byteCounts return the bytes read and written for the request so far,
+// false if the connection does not count them
+func (t *connTracker) byteCounts() (int64, int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	read, written := t.counts()
+	return read, written, t.counted
+}
+
+func (t *connTracker) done() {
+	t.mu.Lock()
+	t.release()
+	t.mu.Unlock()
+}
+
+// trackedBody releases the connection on EOF or Close
+type trackedBody struct {
+	io.ReadCloser
+	tracker *connTracker
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.tracker.done()
+	}
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	b.tracker.done()
+	return b.ReadCloser.Close()
+}
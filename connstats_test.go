@@ -0,0 +1,42 @@
+package curl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseBytes(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		option  ConnectionOption
+		counted bool
+	}{
+		{ConnectionOption{InsecureSkipVerify: true}, true},
+		{ConnectionOption{InsecureSkipVerify: true, HTTP2: true}, false},
+	}
+	for _, tt := range tests {
+		client, err := NewClient(&tt.option)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := NewRequest(client).Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Text()
+		read, written := resp.BytesRead(), resp.BytesWritten()
+		if tt.counted && (read <= int64(len("body")) || written <= 0) {
+			t.Errorf("%s: read %d, written %d bytes", resp.Proto, read, written)
+		}
+		if !tt.counted && (read != -1 || written != -1) {
+			t.Errorf("%s: read %d, written %d bytes, want -1", resp.Proto, read, written)
+		}
+	}
+}
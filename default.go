@@ -0,0 +1,53 @@
+package curl
+
+import (
+	"net/http"
+)
+
+// DefaultClient is used by the package level functions, it can be replaced
+// to set timeouts, proxies, ... globally.
+var DefaultClient = new(http.Client)
+
+// SetDefaultClient replaces DefaultClient with a client created with the option
+func SetDefaultClient(option *ConnectionOption) error {
+	client, err := NewClient(option)
+	if err != nil {
+		return err
+	}
+	DefaultClient = client
+	return nil
+}
+
+// Do sends a request of any method, see Request.Do
+func Do(method string, url string, body ...interface{}) (*Response, error) {
+	return NewRequest(DefaultClient).Do(method, url, body...)
+}
+
+func Get(url string) (*Response, error) {
+	return NewRequest(DefaultClient).Get(url)
+}
+
+func Post(url string, body interface{}) (*Response, error) {
+	return NewRequest(DefaultClient).Post(url, body)
+}
+
+func Put(url string, body interface{}) (*Response, error) {
+	return NewRequest(DefaultClient).Put(url, body)
+}
+
+func Patch(url string, body interface{}) (*Response, error) {
+	return NewRequest(DefaultClient).Patch(url, body)
+}
+
+// Delete sends DELETE with an optional body, see Request.Delete
+func Delete(url string, body ...interface{}) (*Response, error) {
+	return NewRequest(DefaultClient).Delete(url, body...)
+}
+
+func Head(url string) (*Response, error) {
+	return NewRequest(DefaultClient).Head(url)
+}
+
+func Options(url string) (*Response, error) {
+	return NewRequest(DefaultClient).Options(url)
+}
@@ -0,0 +1,219 @@
+package curl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// IPFamily restricts the address family used for connections
+type IPFamily int
+
+const (
+	IPAny IPFamily = iota
+	IPv4Only
+	IPv6Only
+)
+
+// dialFunc adapts a DialContext function to proxy.Dialer
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (f dialFunc) Dial(network, addr string) (net.Conn, error) {
+	return f(context.Background(), network, addr)
+}
+
+func newDialContext(option *ConnectionOption) (dialFunc, error) {
+	dial := option.DialContext
+	if dial == nil {
+		locals, err := localIPs(option.LocalAddr, option.LocalInterface)
+		if err != nil {
+			return nil, err
+		}
+		dial = bindDialContext(&net.Dialer{
+			Timeout:       option.DialTimeout,
+			KeepAlive:     option.DialKeepAlive,
+			Control:       option.DialControl,
+			FallbackDelay: option.FallbackDelay,
+		}, locals)
+	}
+
+	if option.DNSResolver != nil {
+		dial = resolveDialContext(dial, option.DNSResolver, option.Balancer)
+	} else if option.Balancer != nil {
+		dial = resolveDialContext(dial, net.DefaultResolver, option.Balancer)
+	}
+
+	if option.Resolver != nil {
+		dial = discoveryDialContext(dial, newResolverCache(option.Resolver, option.ResolverRefresh), option.Balancer)
+	}
+
+	if len(option.SRVTargets) > 0 {
+		dial = srvDialContext(dial, option.SRVTargets)
+	}
+
+	if option.IPFamily != IPAny {
+		next := dial
+		suffix := "4"
+		if option.IPFamily == IPv6Only {
+			suffix = "6"
+		}
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if network == "tcp" || network == "udp" {
+				network += suffix
+			}
+			return next(ctx, network, addr)
+		}
+	}
+
+	// all connections go to the unix socket, the url host is only used in Host header
+	if option.UnixSocket != "" {
+		next := dial
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return next(ctx, "unix", option.UnixSocket)
+		}
+	}
+
+	if option.DisableTCPNoDelay {
+		next := dial
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := next(ctx, network, addr)
+			if tc, ok := conn.(*net.TCPConn); ok {
+				tc.SetNoDelay(false)
+			}
+			return conn, err
+		}
+	}
+
+	dial = statsDialContext(dial, option.ConnStats)
+
+	return dial, nil
+}
+
+// localIPs return the egress addresses of localAddr or of the interface
+func localIPs(localAddr, localInterface string) ([]net.IP, error) {
+	if localAddr != "" {
+		ip := net.ParseIP(localAddr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid local address: %s", localAddr)
+		}
+		return []net.IP{ip}, nil
+	}
+
+	if localInterface != "" {
+		iface, err := net.InterfaceByName(localInterface)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		var ips []net.IP
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.IsGlobalUnicast() {
+				ips = append(ips, ipnet.IP)
+			}
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no usable address on interface: %s", localInterface)
+		}
+		return ips, nil
+	}
+
+	return nil, nil
+}
+
+// localAddrKey is the context key of the local addresses of Request.LocalAddr
+type localAddrKey struct{}
+
+// bindDialContext dials from the local address of the family of the remote
+// address, among locals or the addresses of Request.LocalAddr in the context
+func bindDialContext(dialer *net.Dialer, locals []net.IP) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ips := locals
+		if v, ok := ctx.Value(localAddrKey{}).([]net.IP); ok {
+			ips = v
+		}
+		if len(ips) == 0 || network == "unix" {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		var v4, v6 net.IP
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				if v4 == nil {
+					v4 = ip
+				}
+			} else if v6 == nil {
+				v6 = ip
+			}
+		}
+		dial := func(local net.IP, addr string) (net.Conn, error) {
+			if local == nil {
+				return nil, fmt.Errorf("no local address of the family of %s", addr)
+			}
+			d := *dialer
+			d.LocalAddr = &net.TCPAddr{IP: local}
+			return d.DialContext(ctx, network, addr)
+		}
+
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			if ip.To4() != nil {
+				return dial(v4, addr)
+			}
+			return dial(v6, addr)
+		}
+		// the dialer only resolves the addresses of the family of its local address
+		if v6 == nil {
+			return dial(v4, addr)
+		}
+		if v4 == nil {
+			return dial(v6, addr)
+		}
+
+		resolver := dialer.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		addrs, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var first error
+		for _, a := range addrs {
+			local := v4
+			if a.IP.To4() == nil {
+				local = v6
+			}
+			conn, err := dial(local, net.JoinHostPort(a.IP.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if first == nil {
+				first = err
+			}
+		}
+		if first == nil {
+			first = fmt.Errorf("no address for host: %s", host)
+		}
+		return nil, first
+	}
+}
+
+// setLocalAddr binds the connections of t to locals, the default dialer and
+// the dialer of NewClient (without ConnectionOption.DialContext) support it
+func setLocalAddr(t *http.Transport, locals []net.IP) {
+	next := t.DialContext
+	if next == nil {
+		next = bindDialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}, nil)
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(context.WithValue(ctx, localAddrKey{}, locals), network, addr)
+	}
+}
@@ -0,0 +1,126 @@
+package curl
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Target is an address of a service instance
+type Target struct {
+	Addr    string // "host:port"
+	Healthy bool
+}
+
+// Resolver returns the instances of a service name, used to integrate
+// service discovery (Consul, etcd, Kubernetes Endpoints, ...).
+// A name without targets (and without error) is dialed as usual.
+type Resolver interface {
+	Resolve(ctx context.Context, name string) ([]Target, error)
+}
+
+// ResolverFunc adapts a function to Resolver
+type ResolverFunc func(ctx context.Context, name string) ([]Target, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, name string) ([]Target, error) {
+	return f(ctx, name)
+}
+
+// ErrNoHealthyTarget is returned when all the targets of a service are unhealthy
+var ErrNoHealthyTarget = errors.New("no healthy target")
+
+// resolverCache refreshes the targets of a name every interval
+type resolverCache struct {
+	resolver Resolver
+	interval time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*resolverEntry
+}
+
+type resolverEntry struct {
+	targets []Target
+	expires time.Time
+}
+
+func newResolverCache(resolver Resolver, interval time.Duration) *resolverCache {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &resolverCache{
+		resolver: resolver,
+		interval: interval,
+		entries:  make(map[string]*resolverEntry),
+	}
+}
+
+func (c *resolverCache) targets(ctx context.Context, name string) ([]Target, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.targets, nil
+	}
+
+	targets, err := c.resolver.Resolve(ctx, name)
+	if err != nil {
+		// keep using the last known targets
+		if ok {
+			return entry.targets, nil
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[name] = &resolverEntry{targets: targets, expires: time.Now().Add(c.interval)}
+	c.mu.Unlock()
+	return targets, nil
+}
+
+// healthyTargets return the healthy targets
+func healthyTargets(targets []Target) []Target {
+	healthy := make([]Target, 0, len(targets))
+	for _, t := range targets {
+		if t.Healthy {
+			healthy = append(healthy, t)
+		}
+	}
+	return healthy
+}
+
+// discoveryDialContext dials a target of the service named by the url host
+func discoveryDialContext(dial dialFunc, cache *resolverCache, balancer Balancer) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		targets, err := cache.targets(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(targets) == 0 {
+			return dial(ctx, network, addr)
+		}
+
+		healthy := healthyTargets(targets)
+		if len(healthy) == 0 {
+			return nil, ErrNoHealthyTarget
+		}
+		addrs := make([]string, len(healthy))
+		for i, t := range healthy {
+			addrs[i] = t.Addr
+		}
+		if balancer == nil {
+			// random order, spreading the connections
+			rand.Shuffle(len(addrs), func(i, j int) {
+				addrs[i], addrs[j] = addrs[j], addrs[i]
+			})
+		}
+		return dialAddrs(ctx, dial, network, addrs, balancer)
+	}
+}
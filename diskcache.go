@@ -0,0 +1,262 @@
+package curl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DiskCache is a Cache persisted in a directory, the bodies are stored in
+// content-addressed files and the entries in an index file. The least
+// recently used entries are evicted when the bodies exceed MaxSize.
+//
+// A directory must not be shared by DiskCache of several processes.
+type DiskCache struct {
+	Dir     string
+	MaxSize int64
+
+	mu         sync.Mutex
+	index      map[string]*diskCacheEntry
+	refs       map[string]int   // body hash => entries
+	sizes      map[string]int64 // body hash => size
+	evictHooks []func(key string)
+}
+
+// diskCacheEntry is a CacheEntry in the index, the body is referenced by hash
+type diskCacheEntry struct {
+	StatusCode    int         `json:"status_code"`
+	Header        http.Header `json:"header"`
+	RequestHeader http.Header `json:"request_header,omitempty"`
+	Time          time.Time   `json:"time"`
+	Body          string      `json:"body"`
+	Size          int64       `json:"size"`
+	Access        time.Time   `json:"access"`
+}
+
+const diskCacheIndex = "index.json"
+
+// NewDiskCache opens the cache in dir, it is created if not exists
+func NewDiskCache(dir string, maxSize int64) (*DiskCache, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "objects"), 0755); err != nil {
+		return nil, err
+	}
+
+	c := &DiskCache{
+		Dir:     dir,
+		MaxSize: maxSize,
+		index:   make(map[string]*diskCacheEntry),
+		refs:    make(map[string]int),
+		sizes:   make(map[string]int64),
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, diskCacheIndex))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		// a corrupted index is dropped
+		if json.Unmarshal(b, &c.index) != nil {
+			c.index = make(map[string]*diskCacheEntry)
+		}
+	}
+	for key, e := range c.index {
+		if _, err := os.Stat(c.objectPath(e.Body)); err != nil {
+			delete(c.index, key)
+			continue
+		}
+		c.refs[e.Body]++
+		c.sizes[e.Body] = e.Size
+	}
+	return c, nil
+}
+
+func (c *DiskCache) Get(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.index[key]
+	if !ok {
+		return nil, false
+	}
+	body, err := ioutil.ReadFile(c.objectPath(e.Body))
+	if err != nil {
+		c.remove(key)
+		c.saveIndex()
+		return nil, false
+	}
+	e.Access = time.Now()
+	return &CacheEntry{
+		StatusCode:    e.StatusCode,
+		Header:        e.Header.Clone(),
+		Body:          body,
+		Time:          e.Time,
+		RequestHeader: e.RequestHeader,
+	}, true
+}
+
+func (c *DiskCache) Set(key string, entry *CacheEntry) {
+	if entry.Size() > c.MaxSize {
+		c.Delete(key)
+		return
+	}
+
+	sum := sha256.Sum256(entry.Body)
+	hash := hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	c.remove(key)
+	if c.refs[hash] == 0 {
+		if err := c.writeObject(hash, entry.Body); err != nil {
+			c.saveIndex()
+			c.mu.Unlock()
+			return
+		}
+	}
+	c.index[key] = &diskCacheEntry{
+		StatusCode:    entry.StatusCode,
+		Header:        entry.Header,
+		RequestHeader: entry.RequestHeader,
+		Time:          entry.Time,
+		Body:          hash,
+		Size:          entry.Size(),
+		Access:        time.Now(),
+	}
+	c.refs[hash]++
+	c.sizes[hash] = entry.Size()
+
+	evicted := c.evict()
+	c.saveIndex()
+	hooks := c.evictHooks
+	c.mu.Unlock()
+
+	for _, key := range evicted {
+		for _, hook := range hooks {
+			hook(key)
+		}
+	}
+}
+
+func (c *DiskCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.index[key]; ok {
+		c.remove(key)
+		c.saveIndex()
+	}
+}
+
+func (c *DiskCache) Keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.index))
+	for key := range c.index {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// OnEvict adds a hook called with the key of the entries evicted by size
+func (c *DiskCache) OnEvict(hook func(key string)) {
+	c.mu.Lock()
+	c.evictHooks = append(c.evictHooks, hook)
+	c.mu.Unlock()
+}
+
+// remove must be called with c.mu held, the body file is deleted when unreferenced
+func (c *DiskCache) remove(key string) {
+	e, ok := c.index[key]
+	if !ok {
+		return
+	}
+	delete(c.index, key)
+	if c.refs[e.Body]--; c.refs[e.Body] <= 0 {
+		delete(c.refs, e.Body)
+		delete(c.sizes, e.Body)
+		os.Remove(c.objectPath(e.Body))
+	}
+}
+
+// evict must be called with c.mu held, it return the evicted keys
+func (c *DiskCache) evict() []string {
+	var size int64
+	for _, s := range c.sizes {
+		size += s
+	}
+	if size <= c.MaxSize {
+		return nil
+	}
+
+	keys := make([]string, 0, len(c.index))
+	for key := range c.index {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.index[keys[i]].Access.Before(c.index[keys[j]].Access)
+	})
+	var evicted []string
+	for _, key := range keys {
+		if size <= c.MaxSize {
+			break
+		}
+		hash := c.index[key].Body
+		if c.refs[hash] == 1 {
+			size -= c.sizes[hash]
+		}
+		c.remove(key)
+		evicted = append(evicted, key)
+	}
+	return evicted
+}
+
+func (c *DiskCache) objectPath(hash string) string {
+	if len(hash) < 2 {
+		return filepath.Join(c.Dir, "objects", hash)
+	}
+	return filepath.Join(c.Dir, "objects", hash[:2], hash)
+}
+
+func (c *DiskCache) writeObject(hash string, body []byte) error {
+	path := c.objectPath(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, body)
+}
+
+// saveIndex must be called with c.mu held
+func (c *DiskCache) saveIndex() error {
+	b, err := json.Marshal(c.index)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(c.Dir, diskCacheIndex), b)
+}
+
+// writeFileAtomic writes a temp file renamed to filename
+func writeFileAtomic(filename string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), filename); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package curl
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DNSCache is a DNSResolver caching lookups of another resolver.
+//
+//	cache := curl.NewDNSCache(nil, time.Minute, 5*time.Second)
+//	client, _ := curl.NewClient(&curl.ConnectionOption{DNSResolver: cache})
+type DNSCache struct {
+	resolver    DNSResolver
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu      sync.RWMutex
+	entries map[string]*dnsCacheEntry
+
+	hits   uint64
+	misses uint64
+}
+
+type dnsCacheEntry struct {
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+// DNSCacheStats is a snapshot of cache counters
+type DNSCacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// NewDNSCache create a cache in front of resolver (net.DefaultResolver if nil),
+// failed lookups are cached for negativeTTL (disabled if 0).
+func NewDNSCache(resolver DNSResolver, ttl, negativeTTL time.Duration) *DNSCache {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &DNSCache{
+		resolver:    resolver,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]*dnsCacheEntry),
+	}
+}
+
+func (c *DNSCache) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	now := time.Now()
+
+	c.mu.RLock()
+	entry, ok := c.entries[host]
+	c.mu.RUnlock()
+	if ok && now.Before(entry.expires) {
+		atomic.AddUint64(&c.hits, 1)
+		return entry.addrs, entry.err
+	}
+	atomic.AddUint64(&c.misses, 1)
+
+	addrs, err := c.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		// cancellations are not a property of the host
+		if ctx.Err() == nil && c.negativeTTL > 0 {
+			c.store(host, &dnsCacheEntry{err: err, expires: now.Add(c.negativeTTL)})
+		}
+		return nil, err
+	}
+
+	c.store(host, &dnsCacheEntry{addrs: addrs, expires: now.Add(c.ttl)})
+	return addrs, nil
+}
+
+func (c *DNSCache) store(host string, entry *dnsCacheEntry) {
+	c.mu.Lock()
+	c.entries[host] = entry
+	c.mu.Unlock()
+}
+
+// Flush removes all cached entries
+func (c *DNSCache) Flush() {
+	c.mu.Lock()
+	c.entries = make(map[string]*dnsCacheEntry)
+	c.mu.Unlock()
+}
+
+// Stats return the hit/miss counters
+func (c *DNSCache) Stats() DNSCacheStats {
+	c.mu.RLock()
+	n := len(c.entries)
+	c.mu.RUnlock()
+	return DNSCacheStats{
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Entries: n,
+	}
+}
@@ -0,0 +1,141 @@
+package curl
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// StatusError is returned when a response has an unexpected status code
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %s", e.Status)
+}
+
+// Download writes the content of url to w, it return the bytes written
+func (r *Request) Download(url string, w io.Writer) (int64, error) {
+	resp, err := r.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	if !resp.OK() {
+		resp.discard()
+		return 0, &StatusError{resp.StatusCode, resp.Status}
+	}
+	return resp.WriteTo(w)
+}
+
+// Checksum is the expected digest of a download, Value is the hex digest or
+// URL is a sidecar file (e.g. "file.tar.gz.sha256") containing it.
+type Checksum struct {
+	Algorithm string // "sha256" (default), "sha1" or "md5"
+	Value     string
+	URL       string
+}
+
+// ChecksumError is returned when the downloaded content does not match the checksum
+type ChecksumError struct {
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %s, got %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+func (c *Checksum) newHash() (hash.Hash, error) {
+	switch strings.ToLower(c.Algorithm) {
+	case "", "sha256":
+		return sha256.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "md5":
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm: %s", c.Algorithm)
+}
+
+// expected return the expected hex digest, fetching the sidecar file if needed
+func (c *Checksum) expected(r *Request) (string, error) {
+	if c.Value != "" {
+		return strings.ToLower(c.Value), nil
+	}
+
+	resp, err := r.Get(c.URL)
+	if err != nil {
+		return "", err
+	}
+	if !resp.OK() {
+		resp.discard()
+		return "", &StatusError{resp.StatusCode, resp.Status}
+	}
+	text, err := resp.Text()
+	if err != nil {
+		return "", err
+	}
+	// "<digest>  <filename>" format of sha256sum
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file: %s", c.URL)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// DownloadFile saves the content of url into filename, the file is removed
+// if the download fails or does not match the checksum (optional).
+func (r *Request) DownloadFile(url string, filename string, checksum *Checksum) (n int64, err error) {
+	var h hash.Hash
+	if checksum != nil {
+		if h, err = checksum.newHash(); err != nil {
+			return 0, err
+		}
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(filename)
+		}
+	}()
+
+	var w io.Writer = f
+	if h != nil {
+		w = io.MultiWriter(f, h)
+	}
+	if n, err = r.Download(url, w); err != nil {
+		return n, err
+	}
+
+	if checksum != nil {
+		expected, err := checksum.expected(r)
+		if err != nil {
+			return n, err
+		}
+		actual := hex.EncodeToString(h.Sum(nil))
+		if actual != expected {
+			algorithm := checksum.Algorithm
+			if algorithm == "" {
+				algorithm = "sha256"
+			}
+			return n, &ChecksumError{algorithm, expected, actual}
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,76 @@
+package curl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// ErrDryRun is wrapped by the DryRunError returned by the calls of a dry-run request
+var ErrDryRun = errors.New("dry run, request not sent")
+
+// DryRunError holds the request prepared by a call in dry-run mode, its body
+// is buffered and can be read after the call
+type DryRunError struct {
+	Request *http.Request
+}
+
+func (e *DryRunError) Error() string {
+	return ErrDryRun.Error() + ": " + e.Request.Method + " " + e.Request.URL.String()
+}
+
+func (e *DryRunError) Unwrap() error {
+	return ErrDryRun
+}
+
+// newDryRunError buffers the body of req, the payload is closed by the call
+func newDryRunError(req *http.Request) error {
+	if req.Body != nil && req.Body != http.NoBody {
+		buf, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(buf)), nil
+		}
+	}
+	return &DryRunError{req}
+}
+
+// WithDryRun prepares the calls without sending them, they return a
+// *DryRunError holding the prepared request
+func (r *Request) WithDryRun() *Request {
+	r.DryRun = true
+	return r
+}
+
+// Build return the request prepared like Call (url, query, headers, auth and
+// encoded body) without sending it, e.g. for inspection or for another
+// transport. The per call options are consumed. The body is not closed, it
+// is closed by the transport sending the request.
+//
+// The cookies are stored in the client jar and sent by the client, the
+// authentication negotiated on challenge (digest) is not applied.
+func (r *Request) Build(method string, url string, body interface{}) (*http.Request, error) {
+	return r.BuildContext(context.Background(), method, url, body)
+}
+
+// BuildContext is Build with a context
+func (r *Request) BuildContext(ctx context.Context, method string, url string, body interface{}) (*http.Request, error) {
+	payload, err := newPayload(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := r.build(ctx, method, url, payload)
+	if err != nil {
+		r.reset(payload)
+		return nil, err
+	}
+	r.reset(emptyPayload)
+	return req, nil
+}
@@ -0,0 +1,101 @@
+package curl
+
+import (
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ContentDecoders decodes response bodies by Content-Encoding, the Accept-Encoding
+// header advertises them. Register br or zstd decoders to accept them:
+//
+//	curl.ContentDecoders["br"] = func(r io.Reader) (io.ReadCloser, error) {
+//		return ioutil.NopCloser(brotli.NewReader(r)), nil
+//	}
+var ContentDecoders = map[string]func(r io.Reader) (io.ReadCloser, error){
+	"gzip": newGzipReader,
+	"deflate": func(r io.Reader) (io.ReadCloser, error) {
+		return zlib.NewReader(r)
+	},
+}
+
+// preferred encodings first, the others are sorted by name
+var encodingOrder = []string{"zstd", "br", "gzip", "deflate"}
+
+// acceptEncoding return the Accept-Encoding value of ContentDecoders
+func acceptEncoding() string {
+	var names []string
+	for _, name := range encodingOrder {
+		if _, ok := ContentDecoders[name]; ok {
+			names = append(names, name)
+		}
+	}
+	var others []string
+	for name := range ContentDecoders {
+		if !containsString(encodingOrder, name) {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	names = append(names, others...)
+
+	if len(names) == 0 {
+		return "identity"
+	}
+	return strings.Join(names, ", ")
+}
+
+// decodeContent decodes r by the Content-Encoding value, the codings are
+// applied in reverse order. Closing the result closes the decoders, not r.
+func decodeContent(r io.ReadCloser, contentEncoding string) (io.ReadCloser, error) {
+	decoded := &decodedReader{ReadCloser: r}
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		if coding == "" || coding == "identity" {
+			continue
+		}
+		decode, ok := ContentDecoders[coding]
+		if !ok {
+			decoded.Close()
+			return nil, fmt.Errorf("unsupported Content-Encoding: %s", coding)
+		}
+		d, err := decode(decoded.ReadCloser)
+		if err != nil {
+			decoded.Close()
+			return nil, err
+		}
+		decoded.ReadCloser = d
+		decoded.decoders = append(decoded.decoders, d)
+	}
+	if len(decoded.decoders) == 0 {
+		return r, nil
+	}
+	return decoded, nil
+}
+
+// decodedReader reads the last decoder, it closes all the decoders, e.g. to
+// return the pooled gzip readers of "gzip, gzip"
+type decodedReader struct {
+	io.ReadCloser
+	decoders []io.ReadCloser
+}
+
+func (d *decodedReader) Close() error {
+	var err error
+	for i := len(d.decoders) - 1; i >= 0; i-- {
+		if cerr := d.decoders[i].Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// WithoutCompression requests uncompressed responses, e.g. for downloads of
+// already compressed files
+func (r *Request) WithoutCompression() *Request {
+	r.DisableCompression = true
+	return r
+}
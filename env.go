@@ -0,0 +1,128 @@
+package curl
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of the environment variables read by NewRequestFromEnv
+var EnvPrefix = "GOREQUEST_"
+
+// ConnectionOptionFromEnv return the option configured by the environment:
+//
+//	GOREQUEST_TIMEOUT                 request timeout, e.g. "10s"
+//	GOREQUEST_DIAL_TIMEOUT            dial timeout
+//	GOREQUEST_PROXY                   proxy url, HTTP_PROXY/HTTPS_PROXY/NO_PROXY are used if not set
+//	GOREQUEST_INSECURE_SKIP_VERIFY    "true" to skip TLS verification
+//	GOREQUEST_CA_FILE                 PEM CA bundle, appended to the system roots
+//	GOREQUEST_TLS_MIN_VERSION         "1.2" or "1.3"
+//	GOREQUEST_HTTP2                   "true" to offer HTTP/2
+//	GOREQUEST_DISABLE_HTTP2           "true" to disable HTTP/2
+func ConnectionOptionFromEnv() (*ConnectionOption, error) {
+	option := new(ConnectionOption)
+	var err error
+
+	if option.RequestTimeout, err = envDuration("TIMEOUT"); err != nil {
+		return nil, err
+	}
+	if option.DialTimeout, err = envDuration("DIAL_TIMEOUT"); err != nil {
+		return nil, err
+	}
+
+	option.ProxyURL = os.Getenv(EnvPrefix + "PROXY")
+	option.ProxyFromEnvironment = option.ProxyURL == ""
+
+	if option.InsecureSkipVerify, err = envBool("INSECURE_SKIP_VERIFY"); err != nil {
+		return nil, err
+	}
+	if option.CAFile = os.Getenv(EnvPrefix + "CA_FILE"); option.CAFile != "" {
+		option.CAAppendSystem = true
+	}
+
+	switch v := os.Getenv(EnvPrefix + "TLS_MIN_VERSION"); v {
+	case "":
+	case "1.0":
+		option.TLSMinVersion = tls.VersionTLS10
+	case "1.1":
+		option.TLSMinVersion = tls.VersionTLS11
+	case "1.2":
+		option.TLSMinVersion = tls.VersionTLS12
+	case "1.3":
+		option.TLSMinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("invalid %sTLS_MIN_VERSION: %s", EnvPrefix, v)
+	}
+
+	if option.HTTP2, err = envBool("HTTP2"); err != nil {
+		return nil, err
+	}
+	if option.DisableHTTP2, err = envBool("DISABLE_HTTP2"); err != nil {
+		return nil, err
+	}
+	return option, nil
+}
+
+// NewRequestFromEnv return a request with a client configured by the environment
+// (see ConnectionOptionFromEnv), plus:
+//
+//	GOREQUEST_USER_AGENT              User-Agent
+//	GOREQUEST_QUERY_MERGE             keep-all, last-wins, first-wins or strict
+//	GOREQUEST_HEADER_<NAME>           default header, "_" in name is "-", e.g. GOREQUEST_HEADER_X_TRACE_ID
+func NewRequestFromEnv() (*Request, error) {
+	option, err := ConnectionOptionFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewClient(option)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewRequest(client)
+	r.UserAgent = os.Getenv(EnvPrefix + "USER_AGENT")
+	if v := os.Getenv(EnvPrefix + "QUERY_MERGE"); v != "" {
+		if r.QueryMerge, err = ParseQueryMerge(v); err != nil {
+			return nil, fmt.Errorf("invalid %sQUERY_MERGE: %v", EnvPrefix, err)
+		}
+	}
+
+	headerPrefix := EnvPrefix + "HEADER_"
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, headerPrefix) {
+			continue
+		}
+		kv = kv[len(headerPrefix):]
+		if eq := strings.IndexByte(kv, '='); eq > 0 {
+			r.WithGlobalHeader(strings.Replace(kv[:eq], "_", "-", -1), kv[eq+1:])
+		}
+	}
+	return r, nil
+}
+
+func envDuration(name string) (time.Duration, error) {
+	v := os.Getenv(EnvPrefix + name)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s%s: %v", EnvPrefix, name, err)
+	}
+	return d, nil
+}
+
+func envBool(name string) (bool, error) {
+	v := os.Getenv(EnvPrefix + name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s%s: %v", EnvPrefix, name, err)
+	}
+	return b, nil
+}
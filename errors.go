@@ -0,0 +1,111 @@
+package curl
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// failure classes of RequestError, test them with errors.Is
+var (
+	ErrTimeout          = errors.New("timeout")
+	ErrDNS              = errors.New("dns lookup failed")
+	ErrTLS              = errors.New("tls failure")
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+// RequestError is returned by failed calls, it carries the request method and url.
+//
+//	if errors.Is(err, curl.ErrTimeout) { ... }
+type RequestError struct {
+	Method string
+	URL    string
+	Kind   error // one of ErrTimeout, ErrDNS, ErrTLS, ErrTooManyRedirects, ErrBodyTooLarge, or nil
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return e.Method + " " + e.URL + ": " + e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// wrapError classifies err of the call of req, the URLs are redacted by x
+func wrapError(req *http.Request, err error, x *Redaction) error {
+	if err == nil {
+		return nil
+	}
+	var rerr *RequestError
+	if errors.As(err, &rerr) {
+		return err
+	}
+	x.redactError(err)
+	return &RequestError{
+		Method: req.Method,
+		URL:    x.URL(req.URL),
+		Kind:   errorKind(err),
+		Err:    err,
+	}
+}
+
+func errorKind(err error) error {
+	if errors.Is(err, ErrBodyTooLarge) {
+		return ErrBodyTooLarge
+	}
+	if errors.Is(err, ErrTooManyRedirects) || strings.Contains(err.Error(), "stopped after") {
+		return ErrTooManyRedirects
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return ErrTimeout
+		}
+		return ErrDNS
+	}
+
+	if isTLSError(err) {
+		return ErrTLS
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrTimeout
+	}
+	return nil
+}
+
+func isTLSError(err error) bool {
+	var (
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		pinErr       *PinError
+	)
+	return errors.As(err, &recordErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &verifyErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) ||
+		errors.As(err, &pinErr)
+}
+
+// checkRedirect limits the redirects to max
+func checkRedirect(max int) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= max {
+			return ErrTooManyRedirects
+		}
+		return nil
+	}
+}
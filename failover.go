@@ -0,0 +1,100 @@
+package curl
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// FailoverStrategy is the order used to try the endpoints
+type FailoverStrategy int
+
+const (
+	// FailoverPriority always starts with the first endpoint
+	FailoverPriority FailoverStrategy = iota
+	// FailoverRoundRobin starts with the next endpoint on each call
+	FailoverRoundRobin
+)
+
+// Endpoints are mirrors of a service, relative call urls are resolved against
+// them and a call fails over to the next endpoint on connect errors or 5xx.
+//
+//	endpoints, _ := curl.NewEndpoints(curl.FailoverPriority, "http://a.example.com/api", "http://b.example.com/api")
+//	resp, err := curl.NewRequest(nil).WithEndpoints(endpoints).Get("/users")
+type Endpoints struct {
+	Strategy FailoverStrategy
+
+	urls []*url.URL
+	next uint32
+}
+
+func NewEndpoints(strategy FailoverStrategy, urls ...string) (*Endpoints, error) {
+	e := &Endpoints{Strategy: strategy}
+	for _, s := range urls {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		e.urls = append(e.urls, u)
+	}
+	return e, nil
+}
+
+// order return the endpoints in the order to be tried for a call
+func (e *Endpoints) order() []*url.URL {
+	if e.Strategy != FailoverRoundRobin || len(e.urls) < 2 {
+		return e.urls
+	}
+	start := int(atomic.AddUint32(&e.next, 1)-1) % len(e.urls)
+	return append(append([]*url.URL(nil), e.urls[start:]...), e.urls[:start]...)
+}
+
+// resolve joins the path and query of ref to the endpoint base
+func resolveEndpoint(base *url.URL, ref *url.URL, merge QueryMerge) (*url.URL, error) {
+	query, err := mergeRawQuery(base.RawQuery, ref.RawQuery, merge)
+	if err != nil {
+		return nil, err
+	}
+	u := *base
+	u.Path = strings.TrimSuffix(base.Path, "/") + "/" + strings.TrimPrefix(ref.Path, "/")
+	u.RawPath = ""
+	u.RawQuery = query
+	u.Fragment = ""
+	return &u, nil
+}
+
+// sendEndpoints sends req to the endpoints in order until one succeeds
+func (r *Request) sendEndpoints(client *http.Client, req *http.Request, stats *callStats) (*Response, error) {
+	endpoints := r.Endpoints.order()
+	if req.URL.IsAbs() || len(endpoints) == 0 {
+		return r.send(client, req, stats)
+	}
+
+	ref := req.URL
+	// the first attempt sends the body, it is rewound for the failovers
+	attempt := req.Clone(req.Context())
+	for i := 0; ; i++ {
+		var err error
+		if attempt.URL, err = resolveEndpoint(endpoints[i], ref, r.QueryMerge); err != nil {
+			return nil, err
+		}
+		attempt.Host = ""
+		applyCookies(attempt, r)
+
+		resp, err := r.send(client, attempt, stats)
+		failed := err != nil || resp.StatusCode >= 500
+		if !failed || i == len(endpoints)-1 || req.Context().Err() != nil {
+			return resp, err
+		}
+		next, rerr := rewindRequest(req)
+		if rerr != nil {
+			// the body was consumed, no failover
+			return resp, err
+		}
+		if resp != nil {
+			resp.discard()
+		}
+		attempt = next
+	}
+}
@@ -0,0 +1,127 @@
+package curl
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// GCPMetadataToken fetches the tokens of a service account from the GCE/GKE
+// metadata server: an access token for the Google APIs, or an identity token
+// for Audience (e.g. a Cloud Run or IAP-protected service) if set.
+//
+//	req.Auth = curl.NewGCPAccessToken("https://www.googleapis.com/auth/cloud-platform")
+//	req.Auth = curl.NewGCPIdentityToken("https://service.example.com")
+type GCPMetadataToken struct {
+	Request  *Request // a new request if nil
+	Host     string   // default $GCE_METADATA_HOST or "metadata.google.internal"
+	Account  string   // default "default"
+	Scopes   []string // of the access token
+	Audience string   // of the identity token
+}
+
+// NewGCPAccessToken return the cached access tokens of the default service account
+func NewGCPAccessToken(scopes ...string) *TokenCache {
+	return NewTokenCache(&GCPMetadataToken{Scopes: scopes})
+}
+
+// NewGCPIdentityToken return the cached identity tokens of the default service account
+func NewGCPIdentityToken(audience string) *TokenCache {
+	return NewTokenCache(&GCPMetadataToken{Audience: audience})
+}
+
+func (g *GCPMetadataToken) Token(ctx context.Context) (*Token, error) {
+	host := g.Host
+	if host == "" {
+		host = os.Getenv("GCE_METADATA_HOST")
+	}
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	account := g.Account
+	if account == "" {
+		account = "default"
+	}
+	base := "http://" + host + "/computeMetadata/v1/instance/service-accounts/" + url.PathEscape(account)
+
+	r := NewRequest(nil)
+	if g.Request != nil {
+		r = g.Request.clone()
+	}
+	r.WithHeader("Metadata-Flavor", "Google")
+
+	if g.Audience != "" {
+		query := url.Values{"audience": {g.Audience}, "format": {"full"}}
+		resp, err := r.CallContext(ctx, "GET", base+"/identity?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if !resp.OK() {
+			resp.discard()
+			return nil, &StatusError{resp.StatusCode, resp.Status}
+		}
+		jwt, err := resp.Text()
+		if err != nil {
+			return nil, err
+		}
+		jwt = strings.TrimSpace(jwt)
+		return &Token{Value: jwt, Expiry: jwtExpiry(jwt)}, nil
+	}
+
+	u := base + "/token"
+	if len(g.Scopes) > 0 {
+		u += "?" + url.Values{"scopes": {strings.Join(g.Scopes, ",")}}.Encode()
+	}
+	resp, err := r.CallContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.OK() {
+		resp.discard()
+		return nil, &StatusError{resp.StatusCode, resp.Status}
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}
+	b, err := resp.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("metadata server returned no access token")
+	}
+	return &Token{
+		Value:  token.AccessToken,
+		Type:   token.TokenType,
+		Expiry: time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// jwtExpiry return the "exp" claim of a JWT, zero if not found
+func jwtExpiry(jwt string) time.Time {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
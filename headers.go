@@ -1,16 +1,19 @@
 package curl
 
 import (
+	"io/ioutil"
 	"net/http"
+	"runtime"
+	"strings"
 )
 
-var DefaultUserAgent = "subchen/go-curl"
+// DefaultUserAgent is like "subchen/go-curl/0.1.0 go1.10"
+var DefaultUserAgent = "subchen/go-curl/" + Version + " " + runtime.Version()
 
 var DefaultHeaders = map[string]string{
-	"Connection":      "keep-alive",
-	"Accept-Encoding": "gzip, deflate",
-	"Accept":          "*/*",
-	"User-Agent":      DefaultUserAgent,
+	"Connection": "keep-alive",
+	"Accept":     "*/*",
+	"User-Agent": DefaultUserAgent,
 }
 
 func applyHeaders(req *http.Request, r *Request, contentType string, contentLength int64) {
@@ -27,6 +30,9 @@ func applyHeaders(req *http.Request, r *Request, contentType string, contentLeng
 	for k, v := range r.Headers {
 		req.Header.Set(k, v)
 	}
+	for k, vs := range r.MultiHeaders {
+		req.Header[http.CanonicalHeaderKey(k)] = vs
+	}
 
 	// apply custom global Headers
 	for k, v := range r.GlobalHeaders {
@@ -35,10 +41,39 @@ func applyHeaders(req *http.Request, r *Request, contentType string, contentLeng
 		}
 	}
 
+	// apply client User-Agent
+	if _, ok := req.Header["User-Agent"]; !ok && r.UserAgent != "" {
+		req.Header.Set("User-Agent", r.UserAgent)
+	}
+
 	// apply default headers
 	for k, v := range DefaultHeaders {
 		if _, ok := req.Header[k]; !ok {
 			req.Header.Set(k, v)
 		}
 	}
+
+	// advertise the decodable encodings
+	if r.DisableCompression {
+		req.Header.Set("Accept-Encoding", "identity")
+	} else if _, ok := req.Header["Accept-Encoding"]; !ok {
+		req.Header.Set("Accept-Encoding", acceptEncoding())
+	}
+}
+
+func applyTrailers(req *http.Request, r *Request) {
+	if len(r.Trailers) == 0 {
+		return
+	}
+
+	req.Trailer = make(http.Header)
+	for k, v := range r.Trailers {
+		req.Trailer.Set(k, v)
+	}
+
+	// trailers are only sent with chunked encoding
+	if req.Body == nil || req.Body == http.NoBody {
+		req.Body = ioutil.NopCloser(strings.NewReader(""))
+	}
+	req.ContentLength = -1
 }
@@ -0,0 +1,79 @@
+package curl
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Outcome is the final result of a call, reported to Request.OnComplete hooks
+type Outcome struct {
+	Method         string
+	URL            string
+	StatusCode     int // 0 if the call failed
+	BodyBytes      int64
+	BytesRead      int64 // on the wire for HTTP/1.x, -1 if unavailable, see Response.BytesRead
+	BytesWritten   int64
+	HeaderDuration time.Duration // until the response headers
+	Duration       time.Duration // until the body is consumed
+	Attempts       int           // retries are Attempts - 1
+	Err            error
+}
+
+// complete reports the outcome to the hooks, for a response it is deferred
+// until the body is consumed.
+func (r *Request) complete(req *http.Request, resp *Response, err error, start time.Time, stats *callStats) {
+	outcome := &Outcome{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		HeaderDuration: time.Since(start),
+		Attempts:       stats.attempts,
+		Err:            err,
+	}
+	hooks := r.CompleteHooks
+
+	fire := func() {
+		outcome.Duration = time.Since(start)
+		for _, hook := range hooks {
+			hook(outcome)
+		}
+	}
+
+	if err != nil || resp == nil {
+		fire()
+		return
+	}
+	outcome.StatusCode = resp.StatusCode
+	outcome.URL = resp.Request.URL.String()
+	resp.Body = &completionBody{ReadCloser: resp.Body, outcome: outcome, fire: func() {
+		outcome.BytesRead, outcome.BytesWritten = resp.BytesRead(), resp.BytesWritten()
+		fire()
+	}}
+}
+
+// completionBody counts the body bytes and fires on EOF, error or Close
+type completionBody struct {
+	io.ReadCloser
+	outcome *Outcome
+	fire    func()
+	once    sync.Once
+}
+
+func (b *completionBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.outcome.BodyBytes += int64(n)
+	if err != nil {
+		if err != io.EOF {
+			b.outcome.Err = err
+		}
+		b.once.Do(b.fire)
+	}
+	return n, err
+}
+
+func (b *completionBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.fire)
+	return err
+}
@@ -0,0 +1,144 @@
+package curl
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HSTS remembers the Strict-Transport-Security of the https responses, the
+// later http:// requests (and redirects) to these hosts are upgraded to https.
+// It can be shared between requests.
+//
+//	hsts := curl.NewHSTS("example.com") // preloaded, including subdomains
+//	req := curl.NewRequest(client).WithHSTS(hsts)
+type HSTS struct {
+	mu    sync.Mutex
+	hosts map[string]hstsEntry
+}
+
+type hstsEntry struct {
+	expires    time.Time // zero for preloaded hosts
+	subdomains bool
+}
+
+// NewHSTS return a store with the preloaded hosts, which include their subdomains
+func NewHSTS(preload ...string) *HSTS {
+	h := &HSTS{hosts: make(map[string]hstsEntry)}
+	for _, host := range preload {
+		h.hosts[strings.ToLower(host)] = hstsEntry{subdomains: true}
+	}
+	return h
+}
+
+// WithHSTS upgrades the requests to the known HSTS hosts to https
+func (r *Request) WithHSTS(h *HSTS) *Request {
+	r.HSTS = h
+	return r
+}
+
+// Known return whether the requests to host are upgraded to https
+func (h *HSTS) Known(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	now := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, domain := 0, host; ; i++ {
+		if e, ok := h.hosts[domain]; ok && (i == 0 || e.subdomains) {
+			if e.expires.IsZero() || now.Before(e.expires) {
+				return true
+			}
+			if i == 0 {
+				delete(h.hosts, domain)
+			}
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+}
+
+// upgrade changes u to https if its host is known, it return whether u changed
+func (h *HSTS) upgrade(u *url.URL) bool {
+	if u.Scheme != "http" || !h.Known(u.Hostname()) {
+		return false
+	}
+	u.Scheme = "https"
+	if u.Port() == "80" {
+		u.Host = u.Hostname()
+		if strings.Contains(u.Host, ":") {
+			u.Host = "[" + u.Host + "]"
+		}
+	}
+	return true
+}
+
+// observe records the Strict-Transport-Security header of a response received over TLS
+func (h *HSTS) observe(resp *http.Response) {
+	if resp == nil || resp.TLS == nil || resp.Request == nil {
+		return
+	}
+	value := resp.Header.Get("Strict-Transport-Security")
+	host := strings.TrimSuffix(strings.ToLower(resp.Request.URL.Hostname()), ".")
+	// the IP addresses are not HSTS hosts
+	if value == "" || net.ParseIP(host) != nil {
+		return
+	}
+
+	maxAge := int64(-1)
+	subdomains := false
+	for _, directive := range strings.Split(value, ";") {
+		name, arg := directive, ""
+		if i := strings.IndexByte(directive, '='); i >= 0 {
+			name, arg = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "max-age":
+			if n, err := strconv.ParseInt(arg, 10, 64); err == nil && n >= 0 {
+				maxAge = n
+			}
+		case "includesubdomains":
+			subdomains = true
+		}
+	}
+	if maxAge < 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e, ok := h.hosts[host]; ok && e.expires.IsZero() {
+		// preloaded
+		return
+	}
+	if maxAge == 0 {
+		delete(h.hosts, host)
+		return
+	}
+	h.hosts[host] = hstsEntry{
+		expires:    time.Now().Add(time.Duration(maxAge) * time.Second),
+		subdomains: subdomains,
+	}
+}
+
+// checkRedirect records the HSTS of the redirect response and upgrades the
+// redirected request, before the next check
+func (h *HSTS) checkRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		h.observe(req.Response)
+		if h.upgrade(req.URL) {
+			req.Host = req.URL.Host
+		}
+		if next == nil {
+			return checkRedirect(10)(req, via)
+		}
+		return next(req, via)
+	}
+}
@@ -0,0 +1,254 @@
+package curl
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// HTMLNode is a node of a parsed HTML document
+type HTMLNode struct {
+	*html.Node
+}
+
+// HTML parses the Response Body as an UTF-8 HTML document
+func (resp *Response) HTML() (*HTMLNode, error) {
+	b, err := resp.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return &HTMLNode{doc}, nil
+}
+
+// Find return the descendant elements matching a CSS selector in document order.
+// Supported are type, universal, #id, .class, [attr], [attr=value] selectors,
+// descendant and child (>) combinators, and selector lists (,), attribute
+// values must not contain spaces.
+//
+//	links, err := doc.Find("div.content > ul li a[href]")
+func (n *HTMLNode) Find(selector string) ([]*HTMLNode, error) {
+	groups, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []*HTMLNode
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode {
+				for _, group := range groups {
+					if group.match(c, len(group)-1) {
+						nodes = append(nodes, &HTMLNode{c})
+						break
+					}
+				}
+			}
+			walk(c)
+		}
+	}
+	walk(n.Node)
+	return nodes, nil
+}
+
+// First return the first element matching selector, nil if none, see Find
+func (n *HTMLNode) First(selector string) (*HTMLNode, error) {
+	nodes, err := n.Find(selector)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// Text return the text content of the node and its descendants
+func (n *HTMLNode) Text() string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			sb.WriteString(node.Data)
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n.Node)
+	return sb.String()
+}
+
+// Attr return the value of an attribute, "" if not present
+func (n *HTMLNode) Attr(name string) string {
+	value, _ := htmlAttr(n.Node, name)
+	return value
+}
+
+// HTML return the node rendered as HTML
+func (n *HTMLNode) HTML() string {
+	var buf bytes.Buffer
+	html.Render(&buf, n.Node)
+	return buf.String()
+}
+
+func htmlAttr(node *html.Node, name string) (string, bool) {
+	for _, attr := range node.Attr {
+		if attr.Namespace == "" && attr.Key == name {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// compoundSelector is a sequence of simple selectors without combinator
+type compoundSelector struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrSelector
+	child   bool // combined with the previous compound by ">"
+}
+
+type attrSelector struct {
+	name   string
+	value  string
+	valued bool // [name=value], else [name]
+}
+
+func (s *compoundSelector) match(node *html.Node) bool {
+	if node.Type != html.ElementNode {
+		return false
+	}
+	if s.tag != "" && s.tag != "*" && s.tag != node.Data {
+		return false
+	}
+	if s.id != "" {
+		if id, _ := htmlAttr(node, "id"); id != s.id {
+			return false
+		}
+	}
+	if len(s.classes) > 0 {
+		class, _ := htmlAttr(node, "class")
+		fields := strings.Fields(class)
+		for _, c := range s.classes {
+			if !containsString(fields, c) {
+				return false
+			}
+		}
+	}
+	for _, attr := range s.attrs {
+		value, ok := htmlAttr(node, attr.name)
+		if !ok || (attr.valued && value != attr.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// complexSelector is a list of compounds joined by combinators
+type complexSelector []*compoundSelector
+
+// match checks node against the compounds up to i, right to left
+func (s complexSelector) match(node *html.Node, i int) bool {
+	if !s[i].match(node) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	if s[i].child {
+		return node.Parent != nil && s.match(node.Parent, i-1)
+	}
+	for p := node.Parent; p != nil; p = p.Parent {
+		if s.match(p, i-1) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSelector(selector string) ([]complexSelector, error) {
+	var groups []complexSelector
+	for _, part := range strings.Split(selector, ",") {
+		group, err := parseComplexSelector(part)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+func parseComplexSelector(s string) (complexSelector, error) {
+	invalid := errors.New("invalid selector: " + strings.TrimSpace(s))
+	var group complexSelector
+	child := false
+	for _, token := range strings.Fields(strings.Replace(s, ">", " > ", -1)) {
+		if token == ">" {
+			if len(group) == 0 || child {
+				return nil, invalid
+			}
+			child = true
+			continue
+		}
+		compound, err := parseCompoundSelector(token)
+		if err != nil {
+			return nil, err
+		}
+		compound.child = child
+		child = false
+		group = append(group, compound)
+	}
+	if len(group) == 0 || child {
+		return nil, invalid
+	}
+	return group, nil
+}
+
+func parseCompoundSelector(token string) (*compoundSelector, error) {
+	s := new(compoundSelector)
+	i := strings.IndexAny(token, "#.[")
+	if i < 0 {
+		i = len(token)
+	}
+	s.tag = strings.ToLower(token[:i])
+
+	for rest := token[i:]; rest != ""; {
+		switch rest[0] {
+		case '#', '.':
+			end := strings.IndexAny(rest[1:], "#.[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			name := rest[1:end]
+			if name == "" {
+				return nil, errors.New("invalid selector: " + token)
+			}
+			if rest[0] == '#' {
+				s.id = name
+			} else {
+				s.classes = append(s.classes, name)
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, errors.New("invalid selector: " + token)
+			}
+			name, value, valued := strings.Cut(rest[1:end], "=")
+			value = strings.Trim(value, `"'`)
+			if name == "" {
+				return nil, errors.New("invalid selector: " + token)
+			}
+			s.attrs = append(s.attrs, attrSelector{name, value, valued})
+			rest = rest[end+1:]
+		default:
+			return nil, errors.New("invalid selector: " + token)
+		}
+	}
+	return s, nil
+}
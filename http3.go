@@ -0,0 +1,67 @@
+//go:build http3
+// +build http3
+
+package curl
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// hosts failing over QUIC use the fallback transport for a while
+const http3BrokenTimeout = 5 * time.Minute
+
+// http3Transport sends https requests over HTTP/3 and falls back to HTTP/2 or HTTP/1.1
+type http3Transport struct {
+	h3       *http3.Transport
+	fallback *http.Transport
+	broken   sync.Map // host -> time.Time
+}
+
+func newHTTP3Transport(fallback *http.Transport) (http.RoundTripper, error) {
+	return &http3Transport{
+		h3:       &http3.Transport{TLSClientConfig: fallback.TLSClientConfig.Clone()},
+		fallback: fallback,
+	}, nil
+}
+
+func (t *http3Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" || t.isBroken(req.URL.Host) {
+		return t.fallback.RoundTrip(req)
+	}
+
+	resp, err := t.h3.RoundTrip(req)
+	if err == nil {
+		return resp, nil
+	}
+	if req.Context().Err() != nil {
+		return nil, err
+	}
+
+	t.broken.Store(req.URL.Host, time.Now().Add(http3BrokenTimeout))
+	retry, rerr := rewindRequest(req)
+	if rerr != nil {
+		return nil, err
+	}
+	return t.fallback.RoundTrip(retry)
+}
+
+func (t *http3Transport) isBroken(host string) bool {
+	v, ok := t.broken.Load(host)
+	if !ok {
+		return false
+	}
+	if time.Now().After(v.(time.Time)) {
+		t.broken.Delete(host)
+		return false
+	}
+	return true
+}
+
+func (t *http3Transport) CloseIdleConnections() {
+	t.h3.Close()
+	t.fallback.CloseIdleConnections()
+}
@@ -0,0 +1,13 @@
+//go:build !http3
+// +build !http3
+
+package curl
+
+import (
+	"errors"
+	"net/http"
+)
+
+func newHTTP3Transport(fallback *http.Transport) (http.RoundTripper, error) {
+	return nil, errors.New("HTTP/3 support requires building with -tags http3")
+}
@@ -0,0 +1,34 @@
+package curl
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// IdempotencyKeyHeader is the header stamped by Request.AutoIdempotencyKey
+var IdempotencyKeyHeader = "Idempotency-Key"
+
+// applyIdempotencyKey stamps a key on non-idempotent requests once per call,
+// resent requests of the same call share the headers and so the key.
+func applyIdempotencyKey(req *http.Request, r *Request) {
+	if !r.AutoIdempotencyKey {
+		return
+	}
+	if req.Method != "POST" && req.Method != "PATCH" {
+		return
+	}
+	if req.Header.Get(IdempotencyKeyHeader) != "" {
+		return
+	}
+	req.Header.Set(IdempotencyKeyHeader, newUUID())
+}
+
+// newUUID return a random (version 4) UUID
+func newUUID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
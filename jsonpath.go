@@ -0,0 +1,155 @@
+package curl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrPathNotFound is returned when a path does not exist in the JSON body
+var ErrPathNotFound = errors.New("json path not found")
+
+// GetValue return the value at path in the JSON body, the path is a dot
+// separated list of object keys and array indexes, "#" is the array length
+// and a dot in a key is escaped by "\".
+//
+//	resp.GetValue("data.items.0.id")
+//	resp.GetValue("data.items.#")
+//	resp.GetValue(`headers.x\.trace`)
+func (resp *Response) GetValue(path string) (interface{}, error) {
+	b, err := resp.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	for _, key := range splitPath(path) {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, pathError(path)
+			}
+			v = value
+		case []interface{}:
+			if key == "#" {
+				v = json.Number(strconv.Itoa(len(node)))
+				continue
+			}
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, pathError(path)
+			}
+			v = node[i]
+		default:
+			return nil, pathError(path)
+		}
+	}
+	return v, nil
+}
+
+// GetString return the value at path as string, objects and arrays are
+// returned as JSON and null as "", see GetValue.
+func (resp *Response) GetString(path string) (string, error) {
+	v, err := resp.GetValue(path)
+	if err != nil {
+		return "", err
+	}
+	switch value := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// GetInt return the number at path as int64, see GetValue
+func (resp *Response) GetInt(path string) (int64, error) {
+	v, err := resp.GetValue(path)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, errors.New("json path is not a number: " + path)
+	}
+	return n.Int64()
+}
+
+// GetFloat return the number at path as float64, see GetValue
+func (resp *Response) GetFloat(path string) (float64, error) {
+	v, err := resp.GetValue(path)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, errors.New("json path is not a number: " + path)
+	}
+	return n.Float64()
+}
+
+// GetBool return the boolean at path, see GetValue
+func (resp *Response) GetBool(path string) (bool, error) {
+	v, err := resp.GetValue(path)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, errors.New("json path is not a boolean: " + path)
+	}
+	return b, nil
+}
+
+func pathError(path string) error {
+	return &pathNotFoundError{path}
+}
+
+type pathNotFoundError struct {
+	path string
+}
+
+func (e *pathNotFoundError) Error() string {
+	return ErrPathNotFound.Error() + ": " + e.path
+}
+
+func (e *pathNotFoundError) Unwrap() error {
+	return ErrPathNotFound
+}
+
+// splitPath splits path by unescaped dots
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	var keys []string
+	var key strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; {
+		case c == '\\' && i+1 < len(path):
+			i++
+			key.WriteByte(path[i])
+		case c == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(c)
+		}
+	}
+	return append(keys, key.String())
+}
@@ -0,0 +1,180 @@
+package curl
+
+import (
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// LatencyStats collects the latencies and errors of calls in a log-linear
+// (HDR-style) histogram over a sliding window, the percentiles are within
+// about 6%. It can be shared by the requests of a client and queried at
+// runtime, e.g. by a health endpoint.
+//
+//	stats := curl.NewLatencyStats(time.Minute, 12)
+//	req := curl.NewRequest(client).WithLatencyStats(stats)
+//	snapshot := stats.Snapshot(10 * time.Second)
+type LatencyStats struct {
+	mu    sync.Mutex
+	slot  time.Duration
+	slots []latencySlot
+}
+
+// LatencyStatsSnapshot is the state of the calls over a window
+type LatencyStatsSnapshot struct {
+	Window    time.Duration
+	Count     int64
+	Errors    int64 // failed calls and 5xx responses
+	ErrorRate float64
+	Mean      time.Duration
+	P50       time.Duration
+	P90       time.Duration
+	P95       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+}
+
+const (
+	histSubBuckets = 16 // per power of 2, in microseconds
+	histBuckets    = histSubBuckets * 61
+)
+
+type latencySlot struct {
+	epoch  int64 // time / slot duration
+	count  int64
+	errors int64
+	sum    time.Duration
+	max    time.Duration
+	counts [histBuckets]int64
+}
+
+// NewLatencyStats keeps the calls of the window, in slots which expire
+// one by one, e.g. 12 slots of 5s for a window of 1 minute.
+func NewLatencyStats(window time.Duration, slots int) *LatencyStats {
+	if slots <= 0 {
+		slots = 1
+	}
+	slot := window / time.Duration(slots)
+	if slot <= 0 {
+		slot = time.Second
+	}
+	return &LatencyStats{
+		slot:  slot,
+		slots: make([]latencySlot, slots),
+	}
+}
+
+// WithLatencyStats records the latency until the response headers, and the
+// outcome of every call in s, which can be shared between requests
+func (r *Request) WithLatencyStats(s *LatencyStats) *Request {
+	r.LatencyStats = s
+	return r
+}
+
+// Observe records a call, failed for errors and 5xx responses
+func (s *LatencyStats) Observe(latency time.Duration, failed bool) {
+	epoch := time.Now().UnixNano() / int64(s.slot)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slot := &s.slots[epoch%int64(len(s.slots))]
+	if slot.epoch != epoch {
+		*slot = latencySlot{epoch: epoch}
+	}
+	slot.count++
+	if failed {
+		slot.errors++
+	}
+	slot.sum += latency
+	if latency > slot.max {
+		slot.max = latency
+	}
+	slot.counts[histBucket(latency)]++
+}
+
+// Snapshot return the stats of the last window, rounded up to whole slots,
+// the full window of s if 0
+func (s *LatencyStats) Snapshot(window time.Duration) LatencyStatsSnapshot {
+	n := int64(len(s.slots))
+	if window > 0 {
+		n = int64((window + s.slot - 1) / s.slot)
+		if n > int64(len(s.slots)) {
+			n = int64(len(s.slots))
+		}
+	}
+	epoch := time.Now().UnixNano() / int64(s.slot)
+
+	var (
+		merged   [histBuckets]int64
+		snapshot = LatencyStatsSnapshot{Window: time.Duration(n) * s.slot}
+		sum      time.Duration
+	)
+	s.mu.Lock()
+	for i := range s.slots {
+		slot := &s.slots[i]
+		if slot.count == 0 || slot.epoch <= epoch-n {
+			continue
+		}
+		snapshot.Count += slot.count
+		snapshot.Errors += slot.errors
+		sum += slot.sum
+		if slot.max > snapshot.Max {
+			snapshot.Max = slot.max
+		}
+		for b, c := range slot.counts {
+			merged[b] += c
+		}
+	}
+	s.mu.Unlock()
+
+	if snapshot.Count == 0 {
+		return snapshot
+	}
+	snapshot.ErrorRate = float64(snapshot.Errors) / float64(snapshot.Count)
+	snapshot.Mean = sum / time.Duration(snapshot.Count)
+	snapshot.P50 = histPercentile(&merged, snapshot.Count, 50, snapshot.Max)
+	snapshot.P90 = histPercentile(&merged, snapshot.Count, 90, snapshot.Max)
+	snapshot.P95 = histPercentile(&merged, snapshot.Count, 95, snapshot.Max)
+	snapshot.P99 = histPercentile(&merged, snapshot.Count, 99, snapshot.Max)
+	return snapshot
+}
+
+// histBucket return the bucket of d, exact below 32µs then 16 linear
+// sub-buckets per power of 2
+func histBucket(d time.Duration) int {
+	v := uint64(d / time.Microsecond)
+	if v < 2*histSubBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - 5
+	return shift*histSubBuckets + int(v>>uint(shift))
+}
+
+// histValue return the middle of the bucket range
+func histValue(bucket int) time.Duration {
+	if bucket < 2*histSubBuckets {
+		return time.Duration(bucket) * time.Microsecond
+	}
+	shift := uint(bucket/histSubBuckets - 1)
+	lower := uint64(bucket%histSubBuckets+histSubBuckets) << shift
+	return time.Duration(lower+(uint64(1)<<shift)/2) * time.Microsecond
+}
+
+// histPercentile return the value of the percentile p, at most max
+func histPercentile(counts *[histBuckets]int64, total int64, p float64, max time.Duration) time.Duration {
+	rank := int64(float64(total)*p/100 + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for b, c := range counts {
+		seen += c
+		if seen >= rank {
+			if v := histValue(b); v < max {
+				return v
+			}
+			return max
+		}
+	}
+	return max
+}
@@ -0,0 +1,102 @@
+package curl
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AdaptiveLimiter is an AIMD rate limiter, the rate decreases multiplicatively
+// when the server returns 429/503 and increases additively on success.
+// It is safe to be shared by requests in many goroutines.
+type AdaptiveLimiter struct {
+	MinRate  float64 // requests per second, default minLimiterRate
+	MaxRate  float64
+	Increase float64 // added to the rate on success, default 1
+	Decrease float64 // rate factor on throttle, default 0.5
+
+	mu   sync.Mutex
+	rate float64
+	next time.Time
+}
+
+// minLimiterRate is the floor of the rate without MinRate, a request per
+// minute, so the throttled rate never reaches 0 and an infinite wait
+const minLimiterRate = 1.0 / 60
+
+func NewAdaptiveLimiter(rate, minRate, maxRate float64) *AdaptiveLimiter {
+	return &AdaptiveLimiter{
+		MinRate: minRate,
+		MaxRate: maxRate,
+		rate:    rate,
+	}
+}
+
+// Wait blocks until a request is allowed
+func (l *AdaptiveLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	if l.rate > 0 {
+		l.next = at.Add(time.Duration(float64(time.Second) / l.rate))
+	}
+	l.mu.Unlock()
+
+	return sleepContext(ctx, at.Sub(now))
+}
+
+// Success increases the rate
+func (l *AdaptiveLimiter) Success() {
+	l.mu.Lock()
+	increase := l.Increase
+	if increase == 0 {
+		increase = 1
+	}
+	l.rate += increase
+	if l.MaxRate > 0 && l.rate > l.MaxRate {
+		l.rate = l.MaxRate
+	}
+	l.mu.Unlock()
+}
+
+// Throttle decreases the rate
+func (l *AdaptiveLimiter) Throttle() {
+	l.mu.Lock()
+	decrease := l.Decrease
+	if decrease == 0 {
+		decrease = 0.5
+	}
+	minRate := l.MinRate
+	if minRate <= 0 {
+		minRate = minLimiterRate
+	}
+	l.rate *= decrease
+	if l.rate < minRate {
+		l.rate = minRate
+	}
+	l.mu.Unlock()
+}
+
+// Rate return the current rate in requests per second
+func (l *AdaptiveLimiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// observe adjusts the rate by the response status, errors are ignored
+func (l *AdaptiveLimiter) observe(resp *Response) {
+	if resp == nil {
+		return
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		l.Throttle()
+	default:
+		l.Success()
+	}
+}
@@ -0,0 +1,29 @@
+package curl
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiterMinRate(t *testing.T) {
+	l := NewAdaptiveLimiter(10, 0, 100)
+	for i := 0; i < 2000; i++ {
+		l.Throttle()
+	}
+	if rate := l.Rate(); rate != minLimiterRate {
+		t.Fatalf("rate = %v, want %v", rate, minLimiterRate)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if wait := time.Until(l.next); wait < 59*time.Second || wait > time.Minute {
+		t.Fatalf("next request in %v, want a minute", wait)
+	}
+	if err := l.Wait(ctx); err == nil {
+		t.Fatal("second request allowed")
+	}
+}
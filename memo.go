@@ -0,0 +1,129 @@
+package curl
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Memoizer keeps the successful GET responses by URL for a TTL, regardless
+// of the HTTP caching headers, e.g. for hot configuration fetches. The least
+// recently used responses are dropped above MaxEntries.
+type Memoizer struct {
+	MaxEntries int
+	TTL        time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List // of *memoItem, most recently used first
+	entries map[string]*list.Element
+}
+
+type memoItem struct {
+	key     string
+	entry   *CacheEntry
+	expires time.Time
+}
+
+func NewMemoizer(maxEntries int, ttl time.Duration) *Memoizer {
+	return &Memoizer{
+		MaxEntries: maxEntries,
+		TTL:        ttl,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// WithMemo memoizes the GET responses by m, which can be shared between
+// requests. The responses are keyed by the cache key (the URL by default),
+// so the requests with credentials (Authorization, Cookie or the cookies of
+// the client jar) are not memoized unless WithCacheKey separates the users.
+func (r *Request) WithMemo(m *Memoizer) *Request {
+	r.Memo = m
+	return r
+}
+
+func (m *Memoizer) get(key string) (*CacheEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	item := e.Value.(*memoItem)
+	if time.Now().After(item.expires) {
+		m.lru.Remove(e)
+		delete(m.entries, key)
+		return nil, false
+	}
+	m.lru.MoveToFront(e)
+	return item.entry, true
+}
+
+func (m *Memoizer) set(key string, entry *CacheEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[key]; ok {
+		m.lru.Remove(e)
+	}
+	m.entries[key] = m.lru.PushFront(&memoItem{key, entry, time.Now().Add(m.TTL)})
+	for m.lru.Len() > m.MaxEntries {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoItem).key)
+	}
+}
+
+// Forget drops the memoized response of key, the URL by default
+func (m *Memoizer) Forget(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[key]; ok {
+		m.lru.Remove(e)
+		delete(m.entries, key)
+	}
+}
+
+// sendMemo answers GET requests from r.Memo, or by send and memoizes 2xx responses
+func (r *Request) sendMemo(req *http.Request, send func() (*Response, error)) (*Response, error) {
+	if req.Method != "GET" || req.Header.Get("Range") != "" {
+		return send()
+	}
+	if r.CacheKey == nil && r.credentials(req) {
+		return send()
+	}
+
+	key := r.cacheKey(req)
+	if entry, ok := r.Memo.get(key); ok {
+		return entry.response(req), nil
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 ||
+		resp.ContentLength > CacheMaxEntrySize {
+		return resp, err
+	}
+
+	body, ok, err := bufferBody(resp, CacheMaxEntrySize)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return resp, nil
+	}
+	r.Memo.set(key, &CacheEntry{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		Time:       time.Now(),
+	})
+	return resp, nil
+}
+
+// credentials return whether req is sent with the credentials of a user
+func (r *Request) credentials(req *http.Request) bool {
+	if req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+		return true
+	}
+	return r.Client != nil && r.Client.Jar != nil && len(r.Client.Jar.Cookies(req.URL)) > 0
+}
@@ -0,0 +1,42 @@
+package curl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("user " + req.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	memo := NewMemoizer(10, time.Minute)
+	get := func(r *Request) string {
+		resp, err := r.WithMemo(memo).Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := resp.Text()
+		return body
+	}
+
+	get(NewRequest(nil).WithHeader("Authorization", "alice"))
+	if body := get(NewRequest(nil).WithHeader("Authorization", "bob")); body != "user bob" {
+		t.Fatalf("response of alice served to bob: %q", body)
+	}
+
+	key := CacheKeyHeaders(DefaultCacheKey, "Authorization")
+	get(NewRequest(nil).WithCacheKey(key).WithHeader("Authorization", "alice"))
+	if body := get(NewRequest(nil).WithCacheKey(key).WithHeader("Authorization", "bob")); body != "user bob" {
+		t.Fatalf("response of alice served to bob: %q", body)
+	}
+	if body := get(NewRequest(nil).WithCacheKey(key).WithHeader("Authorization", "alice")); body != "user alice" {
+		t.Fatalf("response of bob served to alice: %q", body)
+	}
+	if len(memo.entries) != 2 {
+		t.Fatalf("%d memoized responses, want 2", len(memo.entries))
+	}
+}
@@ -0,0 +1,47 @@
+package curl
+
+import (
+	"net/http"
+	"time"
+)
+
+// Metadata is the resource information of the response headers
+type Metadata struct {
+	StatusCode    int
+	ContentLength int64 // -1 if unknown
+	ContentType   string
+	LastModified  time.Time // zero if unknown
+	ETag          string
+	AcceptRanges  bool // byte ranges are supported
+}
+
+// Exists return whether the resource was found
+func (m *Metadata) Exists() bool {
+	return m.StatusCode >= 200 && m.StatusCode < 300
+}
+
+// Metadata return the resource metadata of the response headers
+func (resp *Response) Metadata() *Metadata {
+	m := &Metadata{
+		StatusCode:    resp.StatusCode,
+		ContentLength: resp.ContentLength,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ETag:          resp.Header.Get("ETag"),
+		AcceptRanges:  resp.Header.Get("Accept-Ranges") == "bytes",
+	}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		m.LastModified = t
+	}
+	return m
+}
+
+// HeadMetadata issues HEAD to url and return the resource metadata,
+// e.g. for existence and size checks.
+func (r *Request) HeadMetadata(url string) (*Metadata, error) {
+	resp, err := r.Head(url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp.Metadata(), nil
+}
@@ -0,0 +1,341 @@
+package curl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// Mirror downloads URLs into a directory like wget, as Dir/host/path.
+// Interrupted downloads are resumed from their ".part" file by range
+// requests, and links of HTML pages on the same host can be followed.
+//
+//	m := curl.NewMirror(curl.NewRequest(client), "./mirror")
+//	m.Depth = 2
+//	err := m.Fetch(ctx, "https://example.com/docs/")
+type Mirror struct {
+	Request     *Request
+	Dir         string
+	Concurrency int              // parallel downloads, default 4
+	Depth       int              // levels of same-host links followed from HTML pages, 0 for none
+	Limiter     *AdaptiveLimiter // rate limits the requests if not nil
+	Overwrite   bool             // download again the existing files
+	OnFile      func(url, filename string, err error)
+}
+
+// MirrorError is a failed download of Mirror.Fetch
+type MirrorError struct {
+	URL string
+	Err error
+}
+
+func (e *MirrorError) Error() string {
+	return e.URL + ": " + e.Err.Error()
+}
+
+func (e *MirrorError) Unwrap() error {
+	return e.Err
+}
+
+func NewMirror(r *Request, dir string) *Mirror {
+	return &Mirror{Request: r, Dir: dir}
+}
+
+// Fetch downloads urls and the followed links, the error joins the
+// MirrorError of every failed download.
+func (m *Mirror) Fetch(ctx context.Context, urls ...string) error {
+	concurrency := m.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		visited = make(map[string]bool)
+		errs    []error
+	)
+	fail := func(u string, err error) {
+		mu.Lock()
+		errs = append(errs, &MirrorError{u, err})
+		mu.Unlock()
+	}
+
+	var add func(u *url.URL, depth int)
+	add = func(u *url.URL, depth int) {
+		mu.Lock()
+		if visited[u.String()] {
+			mu.Unlock()
+			return
+		}
+		visited[u.String()] = true
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				fail(u.String(), ctx.Err())
+				return
+			}
+			links, err := m.fetch(ctx, u, depth > 0)
+			<-sem
+			if err != nil {
+				fail(u.String(), err)
+				return
+			}
+			for _, link := range links {
+				add(link, depth-1)
+			}
+		}()
+	}
+
+	for _, s := range urls {
+		u, err := url.Parse(s)
+		if err != nil {
+			fail(s, err)
+			continue
+		}
+		u.Fragment = ""
+		add(u, m.Depth)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// fetch downloads u, it return the same-host links of the page if follow
+func (m *Mirror) fetch(ctx context.Context, u *url.URL, follow bool) (links []*url.URL, err error) {
+	filename := m.localPath(u)
+	if m.OnFile != nil {
+		defer func() {
+			m.OnFile(u.String(), filename, err)
+		}()
+	}
+
+	if _, err := os.Stat(filename); err == nil && !m.Overwrite {
+		if follow && isHTMLFile(filename) {
+			return parseLinks(u, filename)
+		}
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+
+	contentType, err := m.download(ctx, u, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if follow && mediaType == "text/html" {
+		return parseLinks(u, filename)
+	}
+	return nil, nil
+}
+
+// download saves u into filename through the ".part" file, resuming it,
+// it return the Content-Type. The validator of the part (ETag or
+// Last-Modified) is kept in the ".part.validator" file and sent as If-Range,
+// so a modified resource is downloaded again instead of being appended.
+func (m *Mirror) download(ctx context.Context, u *url.URL, filename string) (string, error) {
+	part := filename + ".part"
+	validatorFile := part + ".validator"
+
+	var offset int64
+	var validator string
+	if fstat, err := os.Stat(part); err == nil {
+		if b, err := ioutil.ReadFile(validatorFile); err == nil && len(b) > 0 {
+			offset, validator = fstat.Size(), string(b)
+		}
+	}
+
+	r := m.Request.clone()
+	// ranges are on the encoded content, which must not be decoded
+	r.WithoutCompression()
+	if m.Limiter != nil {
+		r.Limiter = m.Limiter
+	}
+	if offset > 0 {
+		r.WithHeader("Range", fmt.Sprintf("bytes=%d-", offset))
+		r.WithHeader("If-Range", validator)
+	}
+	resp, err := r.CallContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	flag := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusOK:
+		flag |= os.O_TRUNC
+		if err := writeValidator(validatorFile, resp.Header); err != nil {
+			resp.discard()
+			return "", err
+		}
+	case http.StatusPartialContent:
+		if start, _, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok || start != offset {
+			resp.discard()
+			return m.restart(ctx, u, filename)
+		}
+		flag |= os.O_APPEND
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.discard()
+		// the part file is complete if it has the size of the resource
+		if _, size, ok := parseContentRange(resp.Header.Get("Content-Range")); offset > 0 && ok && size == offset {
+			os.Remove(validatorFile)
+			return mime.TypeByExtension(path.Ext(filename)), os.Rename(part, filename)
+		}
+		if offset == 0 {
+			return "", &StatusError{resp.StatusCode, resp.Status}
+		}
+		return m.restart(ctx, u, filename)
+	default:
+		resp.discard()
+		return "", &StatusError{resp.StatusCode, resp.Status}
+	}
+
+	f, err := os.OpenFile(part, flag, 0644)
+	if err != nil {
+		resp.discard()
+		return "", err
+	}
+	_, err = resp.WriteTo(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// the part file is kept to be resumed
+		return "", err
+	}
+	os.Remove(validatorFile)
+	return resp.Header.Get("Content-Type"), os.Rename(part, filename)
+}
+
+// restart removes the part file of filename and downloads it from the start
+func (m *Mirror) restart(ctx context.Context, u *url.URL, filename string) (string, error) {
+	part := filename + ".part"
+	if err := os.Remove(part); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	os.Remove(part + ".validator")
+	return m.download(ctx, u, filename)
+}
+
+// writeValidator saves the If-Range validator of the response, a strong
+// ETag or else Last-Modified, the file is removed if there is none
+func writeValidator(filename string, header http.Header) error {
+	validator := header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = header.Get("Last-Modified")
+	}
+	if validator == "" {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return ioutil.WriteFile(filename, []byte(validator), 0644)
+}
+
+// parseContentRange return the first byte and the complete length of
+// "bytes 0-42/100" or "bytes */100", the length is -1 if unknown
+func parseContentRange(v string) (start, size int64, ok bool) {
+	rng, total, found := strings.Cut(strings.TrimPrefix(v, "bytes "), "/")
+	if !found {
+		return 0, 0, false
+	}
+	size = -1
+	if total != "*" {
+		n, err := strconv.ParseInt(total, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		size = n
+	}
+	if rng == "*" {
+		return -1, size, true
+	}
+	first, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	n, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return n, size, true
+}
+
+// localPath return Dir/host/path of u, "index.html" for directories
+func (m *Mirror) localPath(u *url.URL) string {
+	p := u.Path
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += "index.html"
+	}
+	if u.RawQuery != "" {
+		p += "@" + url.QueryEscape(u.RawQuery)
+	}
+	host := strings.Replace(u.Host, ":", "_", -1)
+	return filepath.Join(m.Dir, host, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+func isHTMLFile(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".html" || ext == ".htm"
+}
+
+// parseLinks return the links of the HTML file on the host of base
+func parseLinks(base *url.URL, filename string) ([]*url.URL, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := html.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []*url.URL
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for _, attr := range []string{"href", "src"} {
+				v, ok := htmlAttr(n, attr)
+				if !ok {
+					continue
+				}
+				ref, err := url.Parse(strings.TrimSpace(v))
+				if err != nil {
+					continue
+				}
+				link := base.ResolveReference(ref)
+				link.Fragment = ""
+				if link.Host == base.Host && (link.Scheme == "http" || link.Scheme == "https") {
+					links = append(links, link)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return links, nil
+}
@@ -0,0 +1,181 @@
+package curl
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"sync"
+)
+
+// MultipartProgress is the progress of a streaming multipart upload
+type MultipartProgress struct {
+	Part      int // index of the file in the files
+	Fieldname string
+	Filename  string
+	PartSent  int64 // bytes of the file sent
+	PartSize  int64
+	Sent      int64 // bytes of the body sent, boundaries included
+	Total     int64
+}
+
+// NewStreamingMultipartPayload return a multipart/form-data payload of the
+// files and of the form fields, like NewMultipartPayload, but the files are
+// read while the body is sent instead of being buffered. The content length
+// is computed from the file sizes. progress (optional) is called as the
+// bytes of the files are accepted by the connection.
+//
+//	payload, err := curl.NewStreamingMultipartPayload(files, nil, func(p curl.MultipartProgress) {
+//		fmt.Printf("%s %d/%d, total %d/%d\n", p.Filename, p.PartSent, p.PartSize, p.Sent, p.Total)
+//	})
+func NewStreamingMultipartPayload(files []UploadFile, form interface{}, progress func(MultipartProgress)) (*Payload, error) {
+	values, err := newValues(form)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make([]int64, len(files))
+	for i, file := range files {
+		if sizes[i], err = file.size(); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &multipartStream{
+		files:    files,
+		sizes:    sizes,
+		values:   values,
+		boundary: multipart.NewWriter(nil).Boundary(),
+		progress: progress,
+	}
+	s.pr, s.pw = io.Pipe()
+
+	// the length of the boundaries and part headers, plus the file sizes
+	if s.total, err = s.encode(nil); err != nil {
+		return nil, err
+	}
+
+	return &Payload{
+		reader:        s,
+		closer:        s,
+		contentLength: s.total,
+		contentType:   "multipart/form-data; boundary=" + s.boundary,
+	}, nil
+}
+
+// multipartStream writes the multipart body into a pipe, from its first read
+type multipartStream struct {
+	files    []UploadFile
+	sizes    []int64
+	values   url.Values
+	boundary string
+	total    int64
+	progress func(MultipartProgress)
+
+	once sync.Once
+	pr   *io.PipeReader
+	pw   *io.PipeWriter
+}
+
+func (s *multipartStream) Read(p []byte) (int, error) {
+	s.once.Do(func() {
+		go func() {
+			_, err := s.encode(s.pw)
+			s.pw.CloseWithError(err)
+		}()
+	})
+	return s.pr.Read(p)
+}
+
+// Close stops the encoder if the body was not sent completely
+func (s *multipartStream) Close() error {
+	return s.pr.Close()
+}
+
+// encode writes the parts into w, it only counts the bytes if w is nil
+func (s *multipartStream) encode(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	mw := multipart.NewWriter(cw)
+	if err := mw.SetBoundary(s.boundary); err != nil {
+		return 0, err
+	}
+
+	for i, file := range s.files {
+		part, err := mw.CreateFormFile(file.Fieldname, file.Filename)
+		if err != nil {
+			return cw.n, err
+		}
+		if w == nil {
+			cw.n += s.sizes[i]
+			continue
+		}
+		if err := s.copyFile(i, part, cw); err != nil {
+			return cw.n, err
+		}
+	}
+	for k, vs := range s.values {
+		for _, v := range vs {
+			if err := mw.WriteField(k, v); err != nil {
+				return cw.n, err
+			}
+		}
+	}
+	err := mw.Close()
+	return cw.n, err
+}
+
+func (s *multipartStream) copyFile(i int, w io.Writer, sent *countWriter) error {
+	file := s.files[i]
+	f, err := file.open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pw := &partWriter{w: w, stream: s, sent: sent, progress: MultipartProgress{
+		Part:      i,
+		Fieldname: file.Fieldname,
+		Filename:  file.Filename,
+		PartSize:  s.sizes[i],
+		Total:     s.total,
+	}}
+	n, err := io.CopyN(pw, f, s.sizes[i])
+	if err == io.EOF {
+		return fmt.Errorf("file %s was truncated to %d bytes while sent", file.Filename, n)
+	}
+	return err
+}
+
+// partWriter reports the progress of the file writes
+type partWriter struct {
+	w        io.Writer
+	stream   *multipartStream
+	sent     *countWriter
+	progress MultipartProgress
+}
+
+func (w *partWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.progress.PartSent += int64(n)
+	if w.stream.progress != nil && n > 0 {
+		w.progress.Sent = w.sent.n
+		w.stream.progress(w.progress)
+	}
+	return n, err
+}
+
+// countWriter counts the bytes written into w, w may be nil
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	if w.w == nil {
+		w.n += int64(len(p))
+		return len(p), nil
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,72 @@
+package curl
+
+import (
+	"net/url"
+	"strings"
+)
+
+// WithURLNormalization normalizes the URLs before sending and computing
+// cache keys: lowercase scheme and host, no default port, no dot segments
+// and query parameters sorted by key.
+func (r *Request) WithURLNormalization() *Request {
+	r.NormalizeURL = true
+	return r
+}
+
+// NormalizeURL return the normalized form of u, see Request.WithURLNormalization
+func NormalizeURL(u *url.URL) *url.URL {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	if port := n.Port(); (port == "80" && n.Scheme == "http") || (port == "443" && n.Scheme == "https") {
+		n.Host = strings.TrimSuffix(n.Host, ":"+port)
+	}
+
+	escaped := removeDotSegments(n.EscapedPath())
+	if escaped == "" && n.Host != "" {
+		escaped = "/"
+	}
+	if path, err := url.PathUnescape(escaped); err == nil {
+		n.Path, n.RawPath = path, escaped
+	}
+
+	if n.RawQuery != "" {
+		if query, err := url.ParseQuery(n.RawQuery); err == nil {
+			n.RawQuery = query.Encode()
+		}
+	}
+	return &n
+}
+
+// removeDotSegments removes the "." and ".." segments of path, RFC 3986 5.2.4
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+
+	var out []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 || (len(out) == 1 && out[0] != "") {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+	result := strings.Join(out, "/")
+	if strings.HasPrefix(path, "/") && !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+	return result
+}
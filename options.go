@@ -0,0 +1,79 @@
+package curl
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option customizes a request derived by Request.With
+type Option func(r *Request)
+
+// With return a new request derived from r with the options applied, r is
+// not modified. The derived request shares the client transport (and so
+// the connection pool), e.g. for per-tenant customization.
+//
+//	base := curl.NewRequest(client).WithGlobalHeader("Accept", "application/json")
+//	tenant := base.With(curl.Header("X-Tenant", "acme"), curl.Timeout(5*time.Second))
+func (r *Request) With(options ...Option) *Request {
+	c := r.clone()
+	// the pending per call options are not inherited
+	c.Headers = nil
+	c.MultiHeaders = nil
+	c.Cookies = nil
+	c.Trailers = nil
+	c.RawHeaders = nil
+	c.Query = nil
+	c.Close = false
+
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Header sets a header for all the calls
+func Header(name, value string) Option {
+	return func(r *Request) {
+		r.WithGlobalHeader(name, value)
+	}
+}
+
+// UserAgent sets the User-Agent
+func UserAgent(userAgent string) Option {
+	return func(r *Request) {
+		r.UserAgent = userAgent
+	}
+}
+
+// Auth sets the authorization, see Request.Auth
+func Auth(auth interface{}) Option {
+	return func(r *Request) {
+		r.Auth = auth
+	}
+}
+
+// Timeout sets the timeout of the calls, the client is copied and shares its transport
+func Timeout(timeout time.Duration) Option {
+	return func(r *Request) {
+		client := new(http.Client)
+		if r.Client != nil {
+			*client = *r.Client
+		}
+		client.Timeout = timeout
+		r.Client = client
+	}
+}
+
+// Retry sets the retry policy
+func Retry(policy *RetryPolicy) Option {
+	return func(r *Request) {
+		r.Retry = policy
+	}
+}
+
+// QueryPolicy sets the policy for query keys in several sources
+func QueryPolicy(merge QueryMerge) Option {
+	return func(r *Request) {
+		r.QueryMerge = merge
+	}
+}
@@ -2,9 +2,10 @@ package curl
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"mime"
 	"mime/multipart"
 	"net/url"
@@ -19,15 +20,64 @@ type Payload struct {
 	closer        io.Closer
 	contentLength int64
 	contentType   string
+	done          <-chan struct{} // closed with the body, see Done
+}
+
+// Done return a channel closed when the body is closed, after the call or
+// when it was canceled, nil if the payload does not support it. Producers
+// of NewChannelPayload stop on it.
+func (p *Payload) Done() <-chan struct{} {
+	return p.done
+}
+
+// WithContentType sets the Content-Type of the payload
+func (p *Payload) WithContentType(contentType string) *Payload {
+	p.contentType = contentType
+	return p
 }
 
 type UploadFile struct {
 	Fieldname string
 	Filename  string
+	FS        fs.FS // Filename is opened from FS if not nil, e.g. an embed.FS
+}
+
+func (f *UploadFile) open() (io.ReadCloser, error) {
+	if f.FS != nil {
+		return f.FS.Open(f.Filename)
+	}
+	return os.Open(f.Filename)
+}
+
+func (f *UploadFile) size() (int64, error) {
+	var fstat fs.FileInfo
+	var err error
+	if f.FS != nil {
+		fstat, err = fs.Stat(f.FS, f.Filename)
+	} else {
+		fstat, err = os.Stat(f.Filename)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return fstat.Size(), nil
+}
+
+// PayloadMarshaler is a body type encoding itself, instead of the JSON
+// encoding of the structs, e.g. a protobuf message or a CSV export:
+//
+//	func (r Report) Payload() (*curl.Payload, error) {
+//		return curl.NewBytesPayload(r.CSV()).WithContentType("text/csv"), nil
+//	}
+type PayloadMarshaler interface {
+	Payload() (*Payload, error)
 }
 
 var emptyPayload = new(Payload)
 
+// ErrUnsupportedPayload is wrapped by the errors of unsupported body, form or query types
+var ErrUnsupportedPayload = errors.New("unsupported payload type")
+
 func newPayload(body interface{}) (*Payload, error) {
 	if body == nil {
 		return emptyPayload, nil
@@ -42,12 +92,12 @@ func newPayload(body interface{}) (*Payload, error) {
 		return NewStringPayload(v), nil
 	case []byte:
 		return NewBytesPayload(v), nil
-	case map[string]string:
-		return NewFormPayload(v), nil
-	case map[string][]string:
-		return NewFormPayload(v), nil
-	case url.Values:
-		return NewFormPayload(v), nil
+	case map[string]string, map[string][]string, url.Values:
+		return NewFormPayloadE(v)
+	}
+
+	if v, ok := body.(PayloadMarshaler); ok {
+		return v.Payload()
 	}
 
 	// io.reader
@@ -65,7 +115,7 @@ func newPayload(body interface{}) (*Payload, error) {
 		return NewJSONPayload(body)
 	}
 
-	panic(fmt.Errorf("unsupported payload type: %T", body))
+	return nil, fmt.Errorf("%w: %T", ErrUnsupportedPayload, body)
 }
 
 func NewStringPayload(body string) *Payload {
@@ -93,8 +143,22 @@ func NewFilePayload(filename string) (*Payload, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newFilePayload(f, filename)
+}
+
+// NewFSFilePayload return a payload of the file name in fsys, e.g. an embed.FS
+func NewFSFilePayload(fsys fs.FS, name string) (*Payload, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return newFilePayload(f, name)
+}
+
+func newFilePayload(f fs.File, filename string) (*Payload, error) {
 	fstat, err := f.Stat()
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
 
@@ -111,31 +175,59 @@ func NewFilePayload(filename string) (*Payload, error) {
 	}, nil
 }
 
+// NewJSONPayload encodes obj by the codec of "application/json", see RegisterCodec
 func NewJSONPayload(obj interface{}) (*Payload, error) {
-	body, err := json.Marshal(obj)
+	c := CodecFor("application/json")
+	body, err := c.Encode(obj)
 	if err != nil {
 		return nil, err
 	}
 	return &Payload{
 		reader:        bytes.NewReader(body),
 		contentLength: int64(len(body)),
-		contentType:   "application/json; charset=utf-8",
+		contentType:   c.ContentType(),
 	}, nil
 }
 
+// NewFormPayload panics if form is not a supported type, for compatibility,
+// use NewFormPayloadE to get an error.
 func NewFormPayload(form interface{}) *Payload {
-	body := newValues(form).Encode()
+	p, err := NewFormPayloadE(form)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// NewFormPayloadE encodes form (url.Values, map[string]string or map[string][]string)
+func NewFormPayloadE(form interface{}) (*Payload, error) {
+	values, err := newValues(form)
+	if err != nil {
+		return nil, err
+	}
+	body := values.Encode()
 	return &Payload{
 		reader:        strings.NewReader(body),
 		contentLength: int64(len(body)),
 		contentType:   "application/x-www-form-urlencoded; charset=utf-8",
-	}
+	}, nil
 }
 
+// NewMultipartPayload return a multipart/form-data payload of the files,
+// opened from disk or from UploadFile.FS, and of the form fields
 func NewMultipartPayload(files []UploadFile, form interface{}) (*Payload, error) {
-	bodyBuffer := new(bytes.Buffer)
+	// the buffer is sized for the files and their part headers, instead of
+	// growing by copies up to twice the body size
+	size := int64(512)
+	for _, file := range files {
+		n, err := file.size()
+		if err != nil {
+			return nil, err
+		}
+		size += n + 256
+	}
+	bodyBuffer := bytes.NewBuffer(make([]byte, 0, size))
 	bodyWriter := multipart.NewWriter(bodyBuffer)
-	defer bodyWriter.Close()
 
 	for _, file := range files {
 		fileWriter, err := bodyWriter.CreateFormFile(file.Fieldname, file.Filename)
@@ -143,26 +235,34 @@ func NewMultipartPayload(files []UploadFile, form interface{}) (*Payload, error)
 			return nil, err
 		}
 
-		f, err := os.Open(file.Filename)
+		f, err := file.open()
 		if err != nil {
 			return nil, err
 		}
-		defer f.Close()
-
 		_, err = io.Copy(fileWriter, f)
+		f.Close()
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	if form != nil {
-		for k, vs := range newValues(form) {
+		values, err := newValues(form)
+		if err != nil {
+			return nil, err
+		}
+		for k, vs := range values {
 			for _, v := range vs {
 				bodyWriter.WriteField(k, v)
 			}
 		}
 	}
 
+	// the closing boundary must be counted in the content length
+	if err := bodyWriter.Close(); err != nil {
+		return nil, err
+	}
+
 	return &Payload{
 		reader:        bodyBuffer,
 		contentLength: int64(bodyBuffer.Len()),
@@ -170,20 +270,47 @@ func NewMultipartPayload(files []UploadFile, form interface{}) (*Payload, error)
 	}, nil
 }
 
-func newValues(value interface{}) url.Values {
-	if value == nil {
+// NewDirMultipartPayload return a multipart/form-data payload of the regular
+// files under dir in fsys, the field names are the paths relative to dir.
+// Use os.DirFS for a directory on disk:
+//
+//	payload, err := curl.NewDirMultipartPayload(os.DirFS("/var/www"), "site", nil)
+func NewDirMultipartPayload(fsys fs.FS, dir string, form interface{}) (*Payload, error) {
+	var files []UploadFile
+	err := fs.WalkDir(fsys, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(name, dir), "/")
+		if dir == "." {
+			rel = name
+		}
+		files = append(files, UploadFile{Fieldname: rel, Filename: name, FS: fsys})
 		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewMultipartPayload(files, form)
+}
+
+func newValues(value interface{}) (url.Values, error) {
+	if value == nil {
+		return nil, nil
 	}
 
 	switch v := value.(type) {
 	case url.Values:
-		return v
+		return v, nil
 	case map[string]string:
 		vals := url.Values{}
 		for k, v := range v {
 			vals.Set(k, v)
 		}
-		return vals
+		return vals, nil
 	case map[string][]string:
 		vals := url.Values{}
 		for k, vs := range v {
@@ -191,7 +318,7 @@ func newValues(value interface{}) url.Values {
 				vals.Add(k, v)
 			}
 		}
-		return vals
+		return vals, nil
 	}
-	panic(fmt.Errorf("unable to convert type %T to url.Values", value))
+	return nil, fmt.Errorf("%w: unable to convert type %T to url.Values", ErrUnsupportedPayload, value)
 }
@@ -0,0 +1,67 @@
+package curl
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type benchmarkBody struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func BenchmarkNewPayloadString(b *testing.B) {
+	var body interface{} = `{"id":1,"name":"curl"}`
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := newPayload(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewPayloadStruct(b *testing.B) {
+	var body interface{} = &benchmarkBody{1, "curl"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := newPayload(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// isStructBody is the struct detection of newPayload
+func isStructBody(body interface{}) bool {
+	t := reflect.TypeOf(body)
+	return t.Kind() == reflect.Struct ||
+		t.Kind() == reflect.Ptr && reflect.ValueOf(body).Elem().Kind() == reflect.Struct
+}
+
+// BenchmarkStructDetection and BenchmarkStructDetectionCached compare the
+// Kind checks of newPayload with a sync.Map cache keyed by reflect.Type, the
+// lookup costs more than the checks it would save.
+func BenchmarkStructDetection(b *testing.B) {
+	var body interface{} = &benchmarkBody{}
+	for i := 0; i < b.N; i++ {
+		if !isStructBody(body) {
+			b.Fatal("not a struct")
+		}
+	}
+}
+
+func BenchmarkStructDetectionCached(b *testing.B) {
+	var cache sync.Map
+	var body interface{} = &benchmarkBody{}
+	for i := 0; i < b.N; i++ {
+		t := reflect.TypeOf(body)
+		v, ok := cache.Load(t)
+		if !ok {
+			v = isStructBody(body)
+			cache.Store(t, v)
+		}
+		if !v.(bool) {
+			b.Fatal("not a struct")
+		}
+	}
+}
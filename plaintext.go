@@ -0,0 +1,69 @@
+package curl
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+	"weak"
+)
+
+// ErrPlaintext is returned for http:// requests refused by ConnectionOption.DenyPlaintext
+var ErrPlaintext = errors.New("plaintext http not allowed")
+
+// plaintextPolicies are the PlaintextHosts of the clients created by
+// NewClient with DenyPlaintext, by weak pointer so a client can be collected
+var plaintextPolicies sync.Map
+
+func setPlaintextPolicy(client *http.Client, hosts []string) {
+	key := weak.Make(client)
+	plaintextPolicies.Store(key, hosts)
+	runtime.AddCleanup(client, func(key weak.Pointer[http.Client]) {
+		plaintextPolicies.Delete(key)
+	}, key)
+}
+
+// checkPlaintext refuses a http:// url if r.Client denies plaintext, except
+// to the loopback and the PlaintextHosts. It is called before every attempt
+// and redirect, whatever the RoundTripper (Request.Transport, raw headers or
+// HTTP/3) sending it.
+func (r *Request) checkPlaintext(u *url.URL) error {
+	v, deny := plaintextPolicies.Load(weak.Make(r.Client))
+	if !deny || u.Scheme != "http" || plaintextAllowed(u.Hostname(), v.([]string)) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPlaintext, u.Host)
+}
+
+// denyPlaintextRedirect wraps the redirect policy next to check the redirects
+func (r *Request) denyPlaintextRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	if next == nil {
+		next = checkRedirect(10)
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if err := next(req, via); err != nil {
+			return err
+		}
+		return r.checkPlaintext(req.URL)
+	}
+}
+
+func plaintextAllowed(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matchWildcard(strings.ToLower(pattern), host) {
+			return true
+		}
+	}
+	return false
+}
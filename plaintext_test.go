@@ -0,0 +1,46 @@
+package curl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDenyPlaintext(t *testing.T) {
+	var reached bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/redirect" {
+			// a host which is not the loopback
+			http.Redirect(w, req, strings.Replace(req.Host, "127.0.0.1", "http://plain.test", 1), http.StatusFound)
+			return
+		}
+		reached = true
+	}))
+	defer srv.Close()
+	client, err := NewClient(&ConnectionOption{DenyPlaintext: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a transport of the request does not bypass the policy
+	_, err = NewRequest(client).WithTransport(http.DefaultTransport).Get("http://plain.test/")
+	if !errors.Is(err, ErrPlaintext) || reached {
+		t.Fatalf("err = %v, reached %v, want ErrPlaintext", err, reached)
+	}
+
+	_, err = NewRequest(client).Get(srv.URL + "/redirect")
+	if !errors.Is(err, ErrPlaintext) || reached {
+		t.Fatalf("redirect: err = %v, reached %v, want ErrPlaintext", err, reached)
+	}
+
+	resp, err := NewRequest(client).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.discard()
+	if !reached {
+		t.Fatal("loopback refused")
+	}
+}
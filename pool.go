@@ -0,0 +1,48 @@
+package curl
+
+import (
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// copyBufferPool holds the buffers of io.CopyBuffer
+var copyBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 64*1024)
+		return &b
+	},
+}
+
+var gzipReaderPool sync.Pool
+
+// pooledGzipReader returns its reader to gzipReaderPool on Close
+type pooledGzipReader struct {
+	*gzip.Reader
+	closed bool
+}
+
+func newGzipReader(r io.Reader) (io.ReadCloser, error) {
+	if z, ok := gzipReaderPool.Get().(*gzip.Reader); ok {
+		if err := z.Reset(r); err != nil {
+			gzipReaderPool.Put(z)
+			return nil, err
+		}
+		return &pooledGzipReader{Reader: z}, nil
+	}
+	z, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledGzipReader{Reader: z}, nil
+}
+
+func (z *pooledGzipReader) Close() error {
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	err := z.Reader.Close()
+	gzipReaderPool.Put(z.Reader)
+	return err
+}
@@ -0,0 +1,193 @@
+package curl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func gzipBody() []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(bytes.Repeat([]byte(`{"id":1,"name":"curl"}`), 100))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func benchmarkGzipDecode(b *testing.B, decode func(r io.Reader) (io.ReadCloser, error)) {
+	body := gzipBody()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			z, err := decode(bytes.NewReader(body))
+			if err != nil {
+				b.Fatal(err)
+			}
+			io.Copy(ioutil.Discard, z)
+			z.Close()
+		}
+	})
+}
+
+func BenchmarkGzipDecodePooled(b *testing.B) {
+	benchmarkGzipDecode(b, newGzipReader)
+}
+
+func BenchmarkGzipDecodeNew(b *testing.B) {
+	benchmarkGzipDecode(b, func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	})
+}
+
+func benchmarkCopyBuffer(b *testing.B, buffer func() (buf []byte, release func())) {
+	body := bytes.Repeat([]byte("x"), 4096)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buf, release := buffer()
+			io.CopyBuffer(ioutil.Discard, bytes.NewReader(body), buf)
+			release()
+		}
+	})
+}
+
+func BenchmarkCopyBufferPooled(b *testing.B) {
+	benchmarkCopyBuffer(b, func() ([]byte, func()) {
+		buf := copyBufferPool.Get().(*[]byte)
+		return *buf, func() { copyBufferPool.Put(buf) }
+	})
+}
+
+func BenchmarkCopyBufferNew(b *testing.B) {
+	benchmarkCopyBuffer(b, func() ([]byte, func()) {
+		return make([]byte, 64*1024), func() {}
+	})
+}
+
+// countingDecoder counts the Close calls of the decoders
+type countingDecoder struct {
+	io.Reader
+	closed *int
+}
+
+func (d countingDecoder) Close() error {
+	*d.closed++
+	return nil
+}
+
+func TestDecodeContentClosesDecoders(t *testing.T) {
+	closed := 0
+	ContentDecoders["x-count"] = func(r io.Reader) (io.ReadCloser, error) {
+		return countingDecoder{r, &closed}, nil
+	}
+	defer delete(ContentDecoders, "x-count")
+
+	body := ioutil.NopCloser(bytes.NewReader([]byte("body")))
+	r, err := decodeContent(body, "x-count, identity, x-count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(r); string(b) != "body" {
+		t.Fatalf("body = %q", b)
+	}
+	r.Close()
+	if closed != 2 {
+		t.Fatalf("closed %d decoders, want 2", closed)
+	}
+}
+
+func TestDecodeContentGzipTwice(t *testing.T) {
+	var inner, outer bytes.Buffer
+	zw := gzip.NewWriter(&inner)
+	zw.Write([]byte("body"))
+	zw.Close()
+	zw = gzip.NewWriter(&outer)
+	zw.Write(inner.Bytes())
+	zw.Close()
+
+	r, err := decodeContent(ioutil.NopCloser(&outer), "gzip, gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil || string(b) != "body" {
+		t.Fatalf("body = %q, %v", b, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func benchmarkMultipart(b *testing.B, newPayload func(files []UploadFile) (*Payload, error)) {
+	filename := filepath.Join(b.TempDir(), "upload.bin")
+	if err := ioutil.WriteFile(filename, make([]byte, 1<<20), 0644); err != nil {
+		b.Fatal(err)
+	}
+	files := []UploadFile{{Fieldname: "file", Filename: filename}}
+
+	b.SetBytes(1 << 20)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		payload, err := newPayload(files)
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(ioutil.Discard, payload.reader)
+		if payload.closer != nil {
+			payload.closer.Close()
+		}
+	}
+}
+
+// BenchmarkMultipartPayload and BenchmarkStreamingMultipartPayload compare
+// the buffered multipart body with the streaming one, which needs no buffer
+// of the file size to pool
+func BenchmarkMultipartPayload(b *testing.B) {
+	benchmarkMultipart(b, func(files []UploadFile) (*Payload, error) {
+		return NewMultipartPayload(files, nil)
+	})
+}
+
+func BenchmarkStreamingMultipartPayload(b *testing.B) {
+	benchmarkMultipart(b, func(files []UploadFile) (*Payload, error) {
+		return NewStreamingMultipartPayload(files, nil, nil)
+	})
+}
+
+var payloadSink *Payload
+
+// BenchmarkJSONPayload and BenchmarkJSONPayloadPooled compare json.Marshal
+// with an encoder writing into a pooled buffer, whose bytes must be copied
+// as the payload outlives the call (retries and Shadow rewind it)
+func BenchmarkJSONPayload(b *testing.B) {
+	v := map[string]interface{}{"id": 1, "name": "curl", "tags": []string{"http", "client"}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		payload, err := NewJSONPayload(v)
+		if err != nil {
+			b.Fatal(err)
+		}
+		payloadSink = payload
+	}
+}
+
+func BenchmarkJSONPayloadPooled(b *testing.B) {
+	pool := sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+	v := map[string]interface{}{"id": 1, "name": "curl", "tags": []string{"http", "client"}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := pool.Get().(*bytes.Buffer)
+		buf.Reset()
+		if err := json.NewEncoder(buf).Encode(v); err != nil {
+			b.Fatal(err)
+		}
+		body := append([]byte(nil), buf.Bytes()...)
+		pool.Put(buf)
+		payloadSink = NewBytesPayload(body).WithContentType("application/json; charset=utf-8")
+	}
+}
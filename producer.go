@@ -0,0 +1,96 @@
+package curl
+
+import (
+	"io"
+	"sync"
+)
+
+// NewProducerPayload return a payload streaming the chunks returned by next
+// until io.EOF. next is only called when the previous chunk was read by the
+// connection, so the producer runs as fast as the network accepts the data
+// and the memory is bounded by a chunk, e.g. to upload a database export:
+//
+//	payload := curl.NewProducerPayload(func() ([]byte, error) {
+//		if !rows.Next() {
+//			return nil, io.EOF
+//		}
+//		return encodeRow(rows)
+//	})
+func NewProducerPayload(next func() ([]byte, error)) *Payload {
+	p := &producer{next: next, done: make(chan struct{})}
+	return &Payload{
+		reader: p,
+		closer: p,
+		done:   p.done,
+	}
+}
+
+// NewChannelPayload return a payload streaming the chunks received from ch
+// until it is closed. The payload is closed when the call ends or is
+// canceled, the producer must stop sending on Done:
+//
+//	ch := make(chan []byte)
+//	payload := curl.NewChannelPayload(ch)
+//	go func() {
+//		defer close(ch)
+//		for chunk := range chunks {
+//			select {
+//			case ch <- chunk:
+//			case <-payload.Done():
+//				return
+//			}
+//		}
+//	}()
+//	resp, err := curl.NewRequest(nil).CallContext(ctx, "POST", url, payload)
+func NewChannelPayload(ch <-chan []byte) *Payload {
+	p := &producer{done: make(chan struct{})}
+	p.next = func() ([]byte, error) {
+		select {
+		case chunk, ok := <-ch:
+			if !ok {
+				return nil, io.EOF
+			}
+			return chunk, nil
+		case <-p.done:
+			return nil, io.ErrClosedPipe
+		}
+	}
+	return &Payload{
+		reader: p,
+		closer: p,
+		done:   p.done,
+	}
+}
+
+// producer reads the chunks of next
+type producer struct {
+	next  func() ([]byte, error)
+	chunk []byte
+	err   error
+
+	once sync.Once
+	done chan struct{}
+}
+
+func (p *producer) Read(b []byte) (int, error) {
+	for len(p.chunk) == 0 {
+		if p.err != nil {
+			return 0, p.err
+		}
+		select {
+		case <-p.done:
+			return 0, io.ErrClosedPipe
+		default:
+		}
+		p.chunk, p.err = p.next()
+	}
+	n := copy(b, p.chunk)
+	p.chunk = p.chunk[n:]
+	return n, nil
+}
+
+// Close stops calling the producer, e.g. when the request failed
+func (p *producer) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
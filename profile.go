@@ -0,0 +1,161 @@
+package curl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ProfileDecoders decodes profile files by extension, register a YAML
+// decoder to load YAML profiles:
+//
+//	curl.ProfileDecoders[".yaml"] = yaml.Unmarshal
+var ProfileDecoders = map[string]func(data []byte, v interface{}) error{
+	".json": json.Unmarshal,
+}
+
+// Profile is a named client configuration
+type Profile struct {
+	BaseURL    string            `json:"base_url" yaml:"base_url"`
+	Headers    map[string]string `json:"headers" yaml:"headers"`
+	UserAgent  string            `json:"user_agent" yaml:"user_agent"`
+	Timeout    Duration          `json:"timeout" yaml:"timeout"`
+	ProxyURL   string            `json:"proxy_url" yaml:"proxy_url"`
+	Auth       *ProfileAuth      `json:"auth" yaml:"auth"`
+	Retry      *ProfileRetry     `json:"retry" yaml:"retry"`
+	QueryMerge string            `json:"query_merge" yaml:"query_merge"` // see ParseQueryMerge
+	TLS        *ProfileTLS       `json:"tls" yaml:"tls"`
+}
+
+type ProfileAuth struct {
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	Token    string `json:"token" yaml:"token"`
+}
+
+type ProfileRetry struct {
+	MaxAttempts    int      `json:"max_attempts" yaml:"max_attempts"`
+	Backoff        Duration `json:"backoff" yaml:"backoff"`
+	MaxBackoff     Duration `json:"max_backoff" yaml:"max_backoff"`
+	Statuses       []int    `json:"statuses" yaml:"statuses"`
+	Methods        []string `json:"methods" yaml:"methods"`
+	MaxElapsed     Duration `json:"max_elapsed" yaml:"max_elapsed"`
+	AttemptTimeout Duration `json:"attempt_timeout" yaml:"attempt_timeout"`
+}
+
+type ProfileTLS struct {
+	InsecureSkipVerify bool   `json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+	CAFile             string `json:"ca_file" yaml:"ca_file"`
+	ClientCertFile     string `json:"client_cert_file" yaml:"client_cert_file"`
+	ClientKeyFile      string `json:"client_key_file" yaml:"client_key_file"`
+}
+
+// Duration is a time.Duration decoded from strings like "10s"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Profiles are profiles by name
+type Profiles map[string]*Profile
+
+// LoadProfiles reads the profiles from a file, the format is chosen by
+// the file extension from ProfileDecoders.
+func LoadProfiles(filename string) (Profiles, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	decode, ok := ProfileDecoders[ext]
+	if !ok {
+		return nil, fmt.Errorf("unsupported profile file format: %s", ext)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(Profiles)
+	if err := decode(data, &profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// NewRequest return a request configured by the named profile
+func (p Profiles) NewRequest(name string) (*Request, error) {
+	profile, ok := p[name]
+	if !ok {
+		return nil, fmt.Errorf("profile not found: %s", name)
+	}
+	return profile.NewRequest()
+}
+
+// NewRequest return a request configured by the profile
+func (p *Profile) NewRequest() (*Request, error) {
+	option := &ConnectionOption{
+		RequestTimeout: time.Duration(p.Timeout),
+		ProxyURL:       p.ProxyURL,
+	}
+	if p.TLS != nil {
+		option.InsecureSkipVerify = p.TLS.InsecureSkipVerify
+		option.CAFile = p.TLS.CAFile
+		option.CAAppendSystem = p.TLS.CAFile != ""
+		option.ClientCertFile = p.TLS.ClientCertFile
+		option.ClientKeyFile = p.TLS.ClientKeyFile
+	}
+
+	client, err := NewClient(option)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewRequest(client)
+	r.UserAgent = p.UserAgent
+	for k, v := range p.Headers {
+		r.WithGlobalHeader(k, v)
+	}
+
+	if p.BaseURL != "" {
+		if r.Endpoints, err = NewEndpoints(FailoverPriority, p.BaseURL); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Auth != nil {
+		if p.Auth.Token != "" {
+			r.WithTokenAuth(p.Auth.Token)
+		} else {
+			r.WithBasicAuth(p.Auth.Username, p.Auth.Password)
+		}
+	}
+
+	if p.QueryMerge != "" {
+		if r.QueryMerge, err = ParseQueryMerge(p.QueryMerge); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Retry != nil {
+		r.Retry = &RetryPolicy{
+			MaxAttempts:    p.Retry.MaxAttempts,
+			Backoff:        time.Duration(p.Retry.Backoff),
+			MaxBackoff:     time.Duration(p.Retry.MaxBackoff),
+			Statuses:       p.Retry.Statuses,
+			Methods:        p.Retry.Methods,
+			MaxElapsed:     time.Duration(p.Retry.MaxElapsed),
+			AttemptTimeout: time.Duration(p.Retry.AttemptTimeout),
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,136 @@
+package curl
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// QueryMerge is the strategy used when a query key comes from several
+// sources: the endpoint base URL, the call URL and Request.Query, merged
+// in this order.
+type QueryMerge int
+
+const (
+	// QueryAppend keeps the values of all the sources (keep-all)
+	QueryAppend QueryMerge = iota
+	// QueryReplace replaces the values of the previous sources (last-wins)
+	QueryReplace
+	// QueryStrict fails the call with ErrQueryConflict (strict)
+	QueryStrict
+	// QueryKeepFirst drops the values of the later sources (first-wins)
+	QueryKeepFirst
+)
+
+var queryMergeNames = map[QueryMerge]string{
+	QueryAppend:    "keep-all",
+	QueryReplace:   "last-wins",
+	QueryStrict:    "strict",
+	QueryKeepFirst: "first-wins",
+}
+
+func (m QueryMerge) String() string {
+	if name, ok := queryMergeNames[m]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseQueryMerge parses a policy name: keep-all, last-wins, first-wins or strict
+func ParseQueryMerge(name string) (QueryMerge, error) {
+	for m, n := range queryMergeNames {
+		if n == name {
+			return m, nil
+		}
+	}
+	return 0, errors.New("unknown query merge policy: " + name)
+}
+
+// ErrQueryConflict is returned by QueryStrict when a key is in several sources
+var ErrQueryConflict = errors.New("query parameter in several sources")
+
+// WithQuery adds query parameters to the call URL, merged by Request.QueryMerge
+func (r *Request) WithQuery(key string, values ...string) *Request {
+	if r.Query == nil {
+		r.Query = make(url.Values)
+	}
+	r.Query[key] = append(r.Query[key], values...)
+	return r
+}
+
+// WithQueryMerge sets the strategy for query keys in several sources
+func (r *Request) WithQueryMerge(merge QueryMerge) *Request {
+	r.QueryMerge = merge
+	return r
+}
+
+// mergeRawQuery merges the raw query next into the raw query prev, prev is
+// kept as is but the keys replaced.
+func mergeRawQuery(prev, next string, merge QueryMerge) (string, error) {
+	if prev == "" || next == "" {
+		return prev + next, nil
+	}
+
+	nextKeys := make(map[string]bool)
+	for _, pair := range strings.Split(next, "&") {
+		nextKeys[queryKey(pair)] = true
+	}
+
+	switch merge {
+	case QueryReplace:
+		var kept []string
+		for _, pair := range strings.Split(prev, "&") {
+			if !nextKeys[queryKey(pair)] {
+				kept = append(kept, pair)
+			}
+		}
+		prev = strings.Join(kept, "&")
+	case QueryStrict:
+		for _, pair := range strings.Split(prev, "&") {
+			if key := queryKey(pair); nextKeys[key] {
+				return "", &queryConflictError{key}
+			}
+		}
+	case QueryKeepFirst:
+		prevKeys := make(map[string]bool)
+		for _, pair := range strings.Split(prev, "&") {
+			prevKeys[queryKey(pair)] = true
+		}
+		var kept []string
+		for _, pair := range strings.Split(next, "&") {
+			if !prevKeys[queryKey(pair)] {
+				kept = append(kept, pair)
+			}
+		}
+		next = strings.Join(kept, "&")
+		if next == "" {
+			return prev, nil
+		}
+	}
+
+	if prev == "" {
+		return next, nil
+	}
+	return prev + "&" + next, nil
+}
+
+// queryKey return the unescaped key of a "key=value" pair
+func queryKey(pair string) string {
+	key, _, _ := strings.Cut(pair, "=")
+	if unescaped, err := url.QueryUnescape(key); err == nil {
+		return unescaped
+	}
+	return key
+}
+
+type queryConflictError struct {
+	key string
+}
+
+func (e *queryConflictError) Error() string {
+	return ErrQueryConflict.Error() + ": " + e.key
+}
+
+func (e *queryConflictError) Unwrap() error {
+	return ErrQueryConflict
+}
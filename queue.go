@@ -0,0 +1,148 @@
+package curl
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQueueClosed is returned for calls enqueued after Queue.Close
+var ErrQueueClosed = errors.New("request queue is closed")
+
+// Queue dispatches calls by priority to a pool of workers, so interactive
+// calls are not starved by bulk traffic sharing the same client.
+//
+//	queue := curl.NewQueue(8)
+//	bulk := curl.NewRequest(client).WithQueue(queue, 0)
+//	interactive := curl.NewRequest(client).WithQueue(queue, 10)
+type Queue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	jobs   jobHeap
+	seq    uint64
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type queueJob struct {
+	priority int
+	seq      uint64
+	fn       func()
+	index    int // in the heap, -1 once dequeued
+}
+
+// NewQueue starts a queue with workers goroutines
+func NewQueue(workers int) *Queue {
+	if workers <= 0 {
+		workers = 1
+	}
+	q := new(Queue)
+	q.cond = sync.NewCond(&q.mu)
+	q.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Do runs fn in a worker and waits for it, higher priority runs first. If
+// ctx is done before fn is started, fn is removed from the queue and the
+// error of ctx is returned; once started, fn is waited for.
+func (q *Queue) Do(ctx context.Context, priority int, fn func()) error {
+	done := make(chan struct{})
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrQueueClosed
+	}
+	q.seq++
+	job := &queueJob{priority: priority, seq: q.seq, fn: func() {
+		defer close(done)
+		fn()
+	}}
+	heap.Push(&q.jobs, job)
+	q.mu.Unlock()
+	q.cond.Signal()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	if job.index >= 0 {
+		heap.Remove(&q.jobs, job.index)
+		q.mu.Unlock()
+		return ctx.Err()
+	}
+	q.mu.Unlock()
+	<-done
+	return nil
+}
+
+// Len return the number of waiting calls
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs)
+}
+
+// Close stops the workers after the waiting calls are done
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+	q.wg.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		for len(q.jobs) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.jobs) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		job := heap.Pop(&q.jobs).(*queueJob)
+		q.mu.Unlock()
+
+		job.fn()
+	}
+}
+
+// jobHeap is ordered by priority desc, then FIFO
+type jobHeap []*queueJob
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *jobHeap) Push(x interface{}) {
+	job := x.(*queueJob)
+	job.index = len(*h)
+	*h = append(*h, job)
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	job := old[len(old)-1]
+	job.index = -1
+	*h = old[:len(old)-1]
+	return job
+}
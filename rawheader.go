@@ -0,0 +1,241 @@
+package curl
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// RawHeader is a header sent with exact name casing
+type RawHeader struct {
+	Name  string
+	Value string
+}
+
+// rawTransport writes HTTP/1.1 requests by hand to keep the raw headers
+// order and casing, connections are not pooled and proxies are not supported.
+type rawTransport struct {
+	dial       dialFunc
+	proxy      func(*http.Request) (*url.URL, error)
+	tlsConfig  *tls.Config
+	rawHeaders []RawHeader
+}
+
+func newRawTransport(base http.RoundTripper, rawHeaders []RawHeader) (*rawTransport, error) {
+	t := &rawTransport{
+		dial:       (&net.Dialer{}).DialContext,
+		tlsConfig:  new(tls.Config),
+		rawHeaders: rawHeaders,
+	}
+
+	if base != nil {
+		bt, ok := base.(*http.Transport)
+		if !ok {
+			return nil, errors.New("request.RawHeaders requires client.Transport to be *http.Transport")
+		}
+		t.proxy = bt.Proxy
+		if bt.DialContext != nil {
+			t.dial = bt.DialContext
+		}
+		if bt.TLSClientConfig != nil {
+			t.tlsConfig = bt.TLSClientConfig.Clone()
+		}
+	}
+	return t, nil
+}
+
+func (t *rawTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+
+	if err := t.validate(req); err != nil {
+		return nil, err
+	}
+	if t.proxy != nil {
+		u, err := t.proxy(req)
+		if err != nil {
+			return nil, err
+		}
+		if u != nil {
+			return nil, errors.New("request.RawHeaders does not support proxy")
+		}
+	}
+
+	conn, err := t.connect(req.Context(), req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.write(conn, req); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body = &connBody{resp.Body, conn}
+	return resp, nil
+}
+
+func (t *rawTransport) connect(ctx context.Context, u *url.URL) (net.Conn, error) {
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	conn, err := t.dial(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return conn, nil
+	}
+
+	config := t.tlsConfig.Clone()
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	config.NextProtos = []string{"http/1.1"}
+	tc := tls.Client(conn, config)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// validate rejects the request line and the headers which could inject
+// headers or requests, like net/http does
+func (t *rawTransport) validate(req *http.Request) error {
+	if !httpguts.ValidHeaderFieldName(req.Method) {
+		return fmt.Errorf("invalid method: %q", req.Method)
+	}
+	if uri := req.URL.RequestURI(); strings.ContainsAny(uri, " \r\n") {
+		return fmt.Errorf("invalid request uri: %q", uri)
+	}
+	if !httpguts.ValidHostHeader(req.Host) || !httpguts.ValidHostHeader(req.URL.Host) {
+		return fmt.Errorf("invalid host: %q", req.Host)
+	}
+	for _, h := range t.rawHeaders {
+		if !httpguts.ValidHeaderFieldName(h.Name) {
+			return fmt.Errorf("invalid raw header name: %q", h.Name)
+		}
+		if !httpguts.ValidHeaderFieldValue(h.Value) {
+			return fmt.Errorf("invalid raw header value for %q", h.Name)
+		}
+	}
+	for _, header := range []http.Header{req.Header, req.Trailer} {
+		for k, vs := range header {
+			if !httpguts.ValidHeaderFieldName(k) {
+				return fmt.Errorf("invalid header name: %q", k)
+			}
+			for _, v := range vs {
+				if !httpguts.ValidHeaderFieldValue(v) {
+					return fmt.Errorf("invalid header value for %q", k)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (t *rawTransport) write(conn net.Conn, req *http.Request) error {
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+
+	sent := make(map[string]bool)
+	hasHeader := func(name string) bool {
+		return sent[http.CanonicalHeaderKey(name)]
+	}
+
+	// raw headers first, in order
+	for _, h := range t.rawHeaders {
+		fmt.Fprintf(w, "%s: %s\r\n", h.Name, h.Value)
+		sent[http.CanonicalHeaderKey(h.Name)] = true
+	}
+
+	if !hasHeader("Host") {
+		host := req.Host
+		if host == "" {
+			host = req.URL.Host
+		}
+		fmt.Fprintf(w, "Host: %s\r\n", host)
+	}
+	if !hasHeader("Connection") {
+		fmt.Fprint(w, "Connection: close\r\n")
+	}
+
+	// the others, sorted like net/http does
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		if !hasHeader(k) && k != "Connection" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range req.Header[k] {
+			fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	// a body of length 0 is of unknown length, like net/http
+	chunked := hasBody && req.ContentLength <= 0
+	if chunked {
+		fmt.Fprint(w, "Transfer-Encoding: chunked\r\n")
+	} else if hasBody {
+		fmt.Fprintf(w, "Content-Length: %d\r\n", req.ContentLength)
+	}
+	fmt.Fprint(w, "\r\n")
+
+	if hasBody {
+		if chunked {
+			cw := httputil.NewChunkedWriter(w)
+			if _, err := io.Copy(cw, req.Body); err != nil {
+				return err
+			}
+			cw.Close()
+			// trailers
+			for k, vs := range req.Trailer {
+				for _, v := range vs {
+					fmt.Fprintf(w, "%s: %s\r\n", k, v)
+				}
+			}
+			fmt.Fprint(w, "\r\n")
+		} else if _, err := io.CopyN(w, req.Body, req.ContentLength); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// connBody closes the connection with the body
+type connBody struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (b *connBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.conn.Close()
+	return err
+}
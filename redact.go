@@ -0,0 +1,173 @@
+package curl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Redaction masks the secrets of the requests and responses written to logs
+// and error messages, the zero value masks nothing.
+type Redaction struct {
+	Headers   []string         // header names
+	Params    []string         // query parameter names, case insensitive
+	JSONPaths []string         // paths in JSON bodies, see Response.GetValue, "*" matches any key or index
+	Patterns  []*regexp.Regexp // matches in messages and bodies
+	Mask      string           // default "REDACTED"
+}
+
+// DefaultRedaction is used by the requests without Redaction
+var DefaultRedaction = &Redaction{
+	Headers: []string{
+		"Authorization",
+		"Proxy-Authorization",
+		"Cookie",
+		"Set-Cookie",
+		"X-Api-Key",
+		"X-Auth-Token",
+	},
+	Params: []string{
+		"access_token",
+		"api_key",
+		"apikey",
+		"password",
+		"secret",
+		"signature",
+		"token",
+		"X-Amz-Credential",
+		"X-Amz-Security-Token",
+		"X-Amz-Signature",
+	},
+}
+
+// WithRedaction sets the redaction of the error messages and logs of the calls
+func (r *Request) WithRedaction(x *Redaction) *Request {
+	r.Redaction = x
+	return r
+}
+
+func (r *Request) redaction() *Redaction {
+	if r.Redaction != nil {
+		return r.Redaction
+	}
+	return DefaultRedaction
+}
+
+func (x *Redaction) mask() string {
+	if x.Mask != "" {
+		return x.Mask
+	}
+	return "REDACTED"
+}
+
+// Header return a copy of h with the values of the redacted headers masked
+func (x *Redaction) Header(h http.Header) http.Header {
+	c := h.Clone()
+	for _, name := range x.Headers {
+		name = http.CanonicalHeaderKey(name)
+		for i := range c[name] {
+			c[name][i] = x.mask()
+		}
+	}
+	return c
+}
+
+// URL return u with the password and the redacted query parameters masked
+func (x *Redaction) URL(u *url.URL) string {
+	c := *u
+	if c.User != nil {
+		if _, ok := c.User.Password(); ok {
+			c.User = url.UserPassword(c.User.Username(), x.mask())
+		}
+	}
+	if c.RawQuery != "" && len(x.Params) > 0 {
+		parts := strings.Split(c.RawQuery, "&")
+		for i, part := range parts {
+			name := part
+			if j := strings.IndexByte(part, '='); j >= 0 {
+				name = part[:j]
+			}
+			if key, err := url.QueryUnescape(name); err == nil && containsFold(x.Params, key) {
+				parts[i] = name + "=" + x.mask()
+			}
+		}
+		c.RawQuery = strings.Join(parts, "&")
+	}
+	return x.String(c.String())
+}
+
+// String return s with the matches of Patterns masked
+func (x *Redaction) String(s string) string {
+	for _, re := range x.Patterns {
+		s = re.ReplaceAllString(s, x.mask())
+	}
+	return s
+}
+
+// Body return body with the values at JSONPaths and the matches of Patterns masked
+func (x *Redaction) Body(body []byte) []byte {
+	if len(x.JSONPaths) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var v interface{}
+		if decoder.Decode(&v) == nil {
+			for _, path := range x.JSONPaths {
+				v = x.maskPath(v, splitPath(path))
+			}
+			if b, err := json.Marshal(v); err == nil {
+				body = b
+			}
+		}
+	}
+	for _, re := range x.Patterns {
+		body = re.ReplaceAll(body, []byte(x.mask()))
+	}
+	return body
+}
+
+// maskPath return v with the values at the path keys masked
+func (x *Redaction) maskPath(v interface{}, keys []string) interface{} {
+	if len(keys) == 0 {
+		return x.mask()
+	}
+	key, rest := keys[0], keys[1:]
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, value := range node {
+			if key == "*" || key == k {
+				node[k] = x.maskPath(value, rest)
+			}
+		}
+	case []interface{}:
+		for i, value := range node {
+			if key == "*" || key == strconv.Itoa(i) {
+				node[i] = x.maskPath(value, rest)
+			}
+		}
+	}
+	return v
+}
+
+// redactError masks the URL of the url.Error in err, which is returned by http.Client
+func (x *Redaction) redactError(err error) {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, perr := url.Parse(urlErr.URL); perr == nil {
+			urlErr.URL = x.URL(u)
+		}
+	}
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package curl
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SensitiveHeaders are removed from the redirected requests to another
+// origin (scheme, host and port), unless Request.RedirectAuth
+var SensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Cookie2",
+	"X-Api-Key",
+	"X-Auth-Token",
+}
+
+// WithRedirectAuth keeps the SensitiveHeaders on redirects to another origin,
+// only for trusted redirects
+func (r *Request) WithRedirectAuth() *Request {
+	r.RedirectAuth = true
+	return r
+}
+
+// stripSensitiveHeaders removes the SensitiveHeaders from the redirects
+// leaving the origin of the first request
+func stripSensitiveHeaders(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	if next == nil {
+		next = checkRedirect(10)
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > 0 && origin(req.URL) != origin(via[0].URL) {
+			for _, name := range SensitiveHeaders {
+				req.Header.Del(name)
+			}
+		}
+		return next(req, via)
+	}
+}
+
+// origin return scheme://host:port of u, with the default port
+func origin(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Hostname()) + ":" + port
+}
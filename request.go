@@ -1,25 +1,77 @@
 package curl
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"strings"
+	"time"
+	"weak"
 )
 
 type Request struct {
-	Client        *http.Client
-	GlobalHeaders map[string]string
-	Headers       map[string]string
-	Cookies       map[string]string
-	Auth          interface{}
+	Client             *http.Client
+	GlobalHeaders      map[string]string
+	UserAgent          string
+	Headers            map[string]string
+	MultiHeaders       http.Header
+	Cookies            map[string]string
+	Trailers           map[string]string
+	RawHeaders         []RawHeader
+	Auth               interface{}
+	ProxyAuth          interface{}
+	Transport          http.RoundTripper
+	TLSConfig          *tls.Config
+	Close              bool
+	DisableCompression bool
+	AutoIdempotencyKey bool
+	Retry              *RetryPolicy
+	Limiter            *AdaptiveLimiter
+	BufferLimit        int64
+	Endpoints          *Endpoints
+	Queue              *Queue
+	Priority           int
+	CompleteHooks      []func(*Outcome)
+	RetryHooks         []func(attempt int, delay time.Duration, reason RetryReason) bool
+	Cache              Cache
+	CacheHooks         []func(event CacheEvent, key string)
+	CacheOnly          bool
+	CacheKey           func(req *http.Request) string
+	Memo               *Memoizer
+	NormalizeURL       bool
+	Query              url.Values
+	QueryMerge         QueryMerge
+	LatencyStats       *LatencyStats
+	SlowThreshold      time.Duration
+	Logger             Logger
+	Shadow             *Shadow
+	DryRun             bool
+	Validators         []Validator
+	HSTS               *HSTS
+	RedirectAuth       bool
+	Redaction          *Redaction
+	LocalAddr          string // egress IP of the calls, see ConnectionOption.LocalAddr
+	LocalInterface     string // egress interface of the calls
+
+	transports *derivedTransports
 }
 
 func NewRequest(client *http.Client) *Request {
 	return &Request{
-		Client: client,
+		Client:     client,
+		transports: new(derivedTransports),
 	}
 }
 
 func (r *Request) Call(method string, url string, body interface{}) (*Response, error) {
+	return r.CallContext(context.Background(), method, url, body)
+}
+
+// CallContext is Call with a context, which cancels the call including retries
+func (r *Request) CallContext(ctx context.Context, method string, url string, body interface{}) (*Response, error) {
 	payload, err := newPayload(body)
 	if err != nil {
 		return nil, err
@@ -27,24 +79,252 @@ func (r *Request) Call(method string, url string, body interface{}) (*Response,
 
 	defer r.reset(payload)
 
-	req, err := http.NewRequest(method, url, payload.reader)
+	start := time.Now()
+	req, err := r.build(ctx, method, url, payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.validate(req); err != nil {
+		return nil, err
+	}
+	if r.DryRun {
+		return nil, newDryRunError(req)
+	}
+
+	client, err := r.httpClient()
 	if err != nil {
 		return nil, err
 	}
+	if r.Shadow != nil {
+		r.Shadow.send(client, req, r.QueryMerge, r.redaction(), r.checkPlaintext)
+	}
+
+	var slow *timing
+	if r.SlowThreshold > 0 && r.Logger != nil {
+		slow = new(timing)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), slow.trace()))
+	}
+
+	var resp *Response
+	stats := new(callStats)
+	send := func(req *http.Request, stats *callStats) (*Response, error) {
+		if r.Endpoints != nil {
+			return r.sendEndpoints(client, req, stats)
+		}
+		return r.send(client, req, stats)
+	}
+	sendCached := func() (*Response, error) {
+		if r.Cache != nil || r.CacheOnly {
+			return r.sendCache(req, stats, send)
+		}
+		return send(req, stats)
+	}
+	sendAll := func() {
+		if r.Memo != nil {
+			resp, err = r.sendMemo(req, sendCached)
+		} else {
+			resp, err = sendCached()
+		}
+	}
+	if r.Queue != nil {
+		if qerr := r.Queue.Do(ctx, r.Priority, sendAll); qerr != nil {
+			err = qerr
+		}
+	} else {
+		sendAll()
+	}
+
+	err = wrapError(req, err, r.redaction())
+	if r.HSTS != nil && resp != nil {
+		r.HSTS.observe(resp.Response)
+	}
+	if r.LatencyStats != nil {
+		r.LatencyStats.Observe(time.Since(start), err != nil || resp.StatusCode >= 500)
+	}
+	if slow != nil {
+		r.logSlow(req.Method, r.redaction().URL(req.URL), time.Since(start), resp, err, stats, slow)
+	}
+	if len(r.CompleteHooks) > 0 {
+		r.complete(req, resp, err, start, stats)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if r.BufferLimit > 0 {
+		if err := resp.buffer(r.BufferLimit); err != nil {
+			return nil, wrapError(req, err, r.redaction())
+		}
+	}
+	return resp, nil
+}
+
+// build return the prepared request of the call
+func (r *Request) build(ctx context.Context, method string, url string, payload *Payload) (*http.Request, error) {
+	url, err := idnURL(url)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, payload.reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Query) > 0 {
+		if req.URL.RawQuery, err = mergeRawQuery(req.URL.RawQuery, r.Query.Encode(), r.QueryMerge); err != nil {
+			return nil, err
+		}
+	}
+	if r.NormalizeURL {
+		req.URL = NormalizeURL(req.URL)
+		req.Host = req.URL.Host
+	}
+	if r.HSTS != nil && r.HSTS.upgrade(req.URL) {
+		req.Host = req.URL.Host
+	}
 
 	if r.Client == nil {
 		r.Client = new(http.Client)
 	}
 
-	applyAuth(r)
+	if err := applyAuth(ctx, r); err != nil {
+		return nil, err
+	}
+	if r.ProxyAuth != nil {
+		creds, err := newProxyCredentials(ctx, r.ProxyAuth)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(context.WithValue(req.Context(), proxyAuthKey{}, creds))
+	}
 	applyHeaders(req, r, payload.contentType, payload.contentLength)
 	applyCookies(req, r)
+	applyTrailers(req, r)
+	applyIdempotencyKey(req, r)
 
-	resp, err := r.Client.Do(req)
+	// close the connection after this request, the transport sends "Connection: close"
+	if r.Close {
+		req.Close = true
+		req.Header.Del("Connection")
+	}
+
+	if signer, ok := r.Auth.(requestSigner); ok {
+		if err := signer.Sign(req); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// callStats are collected over the attempts of a call
+type callStats struct {
+	attempts int
+}
+
+// send executes req, retrying it by r.Retry
+func (r *Request) send(client *http.Client, req *http.Request, stats *callStats) (*Response, error) {
+	deadline := r.Retry.deadline(req.Context(), time.Now())
+	for attempt := 1; ; attempt++ {
+		stats.attempts++
+		if r.Limiter != nil {
+			if err := r.Limiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		ctx, cancel := r.Retry.attemptContext(req.Context(), deadline)
+		resp, wrote, err := r.roundTrip(client, req.WithContext(ctx))
+		if r.Limiter != nil {
+			r.Limiter.observe(resp)
+		}
+
+		delay, reason, ok := r.Retry.retry(attempt, req, resp, err, wrote, deadline)
+		if ok && !r.allowRetry(attempt, delay, reason) {
+			ok = false
+		}
+		if !ok {
+			if resp != nil {
+				resp.attempts = attempt
+				resp.Body = &cancelBody{resp.Body, cancel}
+			} else {
+				cancel()
+			}
+			return resp, err
+		}
+
+		retry, rerr := rewindRequest(req)
+		if rerr != nil {
+			if resp != nil {
+				resp.attempts = attempt
+				resp.Body = &cancelBody{resp.Body, cancel}
+			} else {
+				cancel()
+			}
+			return resp, err
+		}
+		if resp != nil {
+			resp.discard()
+		}
+		cancel()
+		if werr := sleepContext(req.Context(), delay); werr != nil {
+			return nil, werr
+		}
+		req = retry
+	}
+}
+
+// roundTrip executes req once, it return whether the request was written
+func (r *Request) roundTrip(client *http.Client, req *http.Request) (*Response, bool, error) {
+	if err := r.checkPlaintext(req.URL); err != nil {
+		return nil, false, err
+	}
+	tracker := new(connTracker)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), tracker.trace()))
+
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		// a CONNECT digest challenge is answered by a new tunnel
+		if creds := proxyCredentialsOf(req.Context()); creds != nil && creds.retryConnect() {
+			if retry, rerr := rewindRequest(req); rerr == nil {
+				resp, err = client.Do(retry)
+			}
+		}
+	}
+	if err != nil {
+		tracker.done()
+		return nil, tracker.wroteRequest(), err
+	}
+
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		if retry := proxyAuthRetry(req, resp, r); retry != nil {
+			resp.Body.Close()
+			if resp, err = client.Do(retry); err != nil {
+				tracker.done()
+				return nil, true, err
+			}
+			req = retry
+		}
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		if retry := authRetry(req, resp, r); retry != nil {
+			resp.Body.Close()
+			if resp, err = client.Do(retry); err != nil {
+				tracker.done()
+				return nil, true, err
+			}
+		}
+	}
+
+	resp.Body = &trackedBody{resp.Body, tracker}
+	return &Response{Response: resp, reused: tracker.reused, tracker: tracker}, true, nil
+}
+
+// Do sends a request of any method, e.g. the WebDAV PROPFIND and MKCOL or
+// PURGE, with an optional body of the types accepted by Post.
+func (r *Request) Do(method string, url string, body ...interface{}) (*Response, error) {
+	if len(body) > 0 {
+		return r.Call(method, url, body[0])
 	}
-	return &Response{resp, nil}, nil
+	return r.Call(method, url, nil)
 }
 
 func (r *Request) Get(url string) (*Response, error) {
@@ -63,7 +343,11 @@ func (r *Request) Patch(url string, body interface{}) (*Response, error) {
 	return r.Call("PATCH", url, body)
 }
 
-func (r *Request) Delete(url string) (*Response, error) {
+// Delete sends DELETE with an optional body, of the types accepted by Post
+func (r *Request) Delete(url string, body ...interface{}) (*Response, error) {
+	if len(body) > 0 {
+		return r.Call("DELETE", url, body[0])
+	}
 	return r.Call("DELETE", url, nil)
 }
 
@@ -87,10 +371,68 @@ func (r *Request) WithHeader(name, value string) *Request {
 	if r.Headers == nil {
 		r.Headers = make(map[string]string)
 	}
+	delete(r.MultiHeaders, http.CanonicalHeaderKey(name))
 	r.Headers[name] = value
 	return r
 }
 
+// SetHeader replaces the header values, multiple values are sent as repeated headers
+func (r *Request) SetHeader(name string, values ...string) *Request {
+	r.deleteHeader(name)
+	if r.MultiHeaders == nil {
+		r.MultiHeaders = make(http.Header)
+	}
+	r.MultiHeaders[http.CanonicalHeaderKey(name)] = values
+	return r
+}
+
+// SetHeaderJoined replaces the header with the values joined by comma in a single header
+func (r *Request) SetHeaderJoined(name string, values ...string) *Request {
+	return r.SetHeader(name, strings.Join(values, ", "))
+}
+
+// AddHeader adds a value to the header, sent as a repeated header
+func (r *Request) AddHeader(name, value string) *Request {
+	if r.MultiHeaders == nil {
+		r.MultiHeaders = make(http.Header)
+	}
+	key := http.CanonicalHeaderKey(name)
+	for k, v := range r.Headers {
+		if http.CanonicalHeaderKey(k) == key {
+			r.MultiHeaders.Add(key, v)
+			delete(r.Headers, k)
+		}
+	}
+	r.MultiHeaders.Add(key, value)
+	return r
+}
+
+func (r *Request) deleteHeader(name string) {
+	key := http.CanonicalHeaderKey(name)
+	for k := range r.Headers {
+		if http.CanonicalHeaderKey(k) == key {
+			delete(r.Headers, k)
+		}
+	}
+	delete(r.MultiHeaders, key)
+}
+
+// WithUserAgent replaces the User-Agent for all calls
+func (r *Request) WithUserAgent(userAgent string) *Request {
+	r.UserAgent = userAgent
+	return r
+}
+
+// WithProductToken prepends a product token to the User-Agent, e.g. "myapp/1.2"
+func (r *Request) WithProductToken(token string) *Request {
+	userAgent := r.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	r.UserAgent = token + " " + userAgent
+	return r
+}
+
 func (r *Request) WithCookie(name, value string) *Request {
 	if r.Cookies == nil {
 		r.Cookies = make(map[string]string)
@@ -99,6 +441,20 @@ func (r *Request) WithCookie(name, value string) *Request {
 	return r
 }
 
+// WithRawHeader adds a header sent as is, raw headers are sent first in insertion order
+func (r *Request) WithRawHeader(name, value string) *Request {
+	r.RawHeaders = append(r.RawHeaders, RawHeader{name, value})
+	return r
+}
+
+func (r *Request) WithTrailer(name, value string) *Request {
+	if r.Trailers == nil {
+		r.Trailers = make(map[string]string)
+	}
+	r.Trailers[name] = value
+	return r
+}
+
 func (r *Request) WithBasicAuth(name, passwd string) *Request {
 	r.Auth = &BasicAuth{name, passwd}
 	return r
@@ -109,23 +465,260 @@ func (r *Request) WithTokenAuth(token string) *Request {
 	return r
 }
 
+// WithDigestAuth answers the Digest challenges of the server, the request
+// body is sent again with the answer
+func (r *Request) WithDigestAuth(name, passwd string) *Request {
+	r.Auth = &DigestAuth{Username: name, Password: passwd}
+	return r
+}
+
+func (r *Request) WithProxyBasicAuth(name, passwd string) *Request {
+	r.ProxyAuth = &BasicAuth{name, passwd}
+	return r
+}
+
+func (r *Request) WithProxyDigestAuth(name, passwd string) *Request {
+	r.ProxyAuth = &DigestAuth{Username: name, Password: passwd}
+	return r
+}
+
+// WithTransport runs the calls on rt instead of the client transport,
+// the client timeout, cookies and redirect policy still apply.
+func (r *Request) WithTransport(rt http.RoundTripper) *Request {
+	r.Transport = rt
+	return r
+}
+
+// WithTLSConfig overrides the fields set in config of the client TLS config,
+// e.g. ServerName or Certificates, the pins and verifications of the client
+// are kept. The connections are pooled per config pointer, the config must be
+// reused by the calls instead of being created per call.
+func (r *Request) WithTLSConfig(config *tls.Config) *Request {
+	r.TLSConfig = config
+	return r
+}
+
+// WithLocalAddr sends the calls from the local IP, the connections are
+// pooled per local address
+func (r *Request) WithLocalAddr(ip string) *Request {
+	r.LocalAddr = ip
+	return r
+}
+
+// WithLocalInterface sends the calls from an address of the network
+// interface, of the family of the remote address
+func (r *Request) WithLocalInterface(name string) *Request {
+	r.LocalInterface = name
+	return r
+}
+
+// CloseIdleConnections closes the idle connections of the client transports
+func (r *Request) CloseIdleConnections() {
+	if r.Client != nil {
+		r.Client.CloseIdleConnections()
+	}
+	if r.transports != nil {
+		r.transports.closeIdleConnections()
+	}
+}
+
+// WithAutoIdempotencyKey stamps an Idempotency-Key on POST and PATCH calls
+func (r *Request) WithAutoIdempotencyKey() *Request {
+	r.AutoIdempotencyKey = true
+	return r
+}
+
+// WithRetry retries failed calls up to maxAttempts times with the default policy
+func (r *Request) WithRetry(maxAttempts int) *Request {
+	r.Retry = &RetryPolicy{MaxAttempts: maxAttempts}
+	return r
+}
+
+// WithLimiter throttles calls by the limiter, which can be shared between requests
+func (r *Request) WithLimiter(limiter *AdaptiveLimiter) *Request {
+	r.Limiter = limiter
+	return r
+}
+
+// WithBufferedBody reads the response body in memory (up to limit bytes)
+// before returning, so it can be read many times.
+func (r *Request) WithBufferedBody(limit int64) *Request {
+	r.BufferLimit = limit
+	return r
+}
+
+// WithEndpoints resolves relative urls against the endpoints, with failover
+func (r *Request) WithEndpoints(endpoints *Endpoints) *Request {
+	r.Endpoints = endpoints
+	return r
+}
+
+// WithQueue dispatches the calls by the queue with the priority
+func (r *Request) WithQueue(queue *Queue, priority int) *Request {
+	r.Queue = queue
+	r.Priority = priority
+	return r
+}
+
+// OnRetry adds a hook called before each retry with the failed attempt number,
+// the delay before the next attempt and the failure class. Returning false
+// stops the retries, the last response or error is returned.
+func (r *Request) OnRetry(hook func(attempt int, delay time.Duration, reason RetryReason) bool) *Request {
+	r.RetryHooks = append(r.RetryHooks, hook)
+	return r
+}
+
+// allowRetry runs the retry hooks, false if one of them vetoes the retry
+func (r *Request) allowRetry(attempt int, delay time.Duration, reason RetryReason) bool {
+	for _, hook := range r.RetryHooks {
+		if !hook(attempt, delay, reason) {
+			return false
+		}
+	}
+	return true
+}
+
+// OnComplete adds a hook called with the outcome of every call, when the
+// response body is consumed or closed, or when the call fails.
+func (r *Request) OnComplete(hook func(*Outcome)) *Request {
+	r.CompleteHooks = append(r.CompleteHooks, hook)
+	return r
+}
+
+// WithoutKeepAlive closes the connection after the next call
+func (r *Request) WithoutKeepAlive() *Request {
+	r.Close = true
+	return r
+}
+
+// clone return a copy of the request which can be used concurrently, the
+// maps and slices are copied so appending to the copy never changes r
+func (r *Request) clone() *Request {
+	c := *r
+	c.GlobalHeaders = copyStringMap(r.GlobalHeaders)
+	c.Headers = copyStringMap(r.Headers)
+	c.MultiHeaders = r.MultiHeaders.Clone()
+	c.Cookies = copyStringMap(r.Cookies)
+	c.Trailers = copyStringMap(r.Trailers)
+	c.RawHeaders = append([]RawHeader(nil), r.RawHeaders...)
+	if r.Query != nil {
+		c.Query = make(url.Values, len(r.Query))
+		for k, vs := range r.Query {
+			c.Query[k] = append([]string(nil), vs...)
+		}
+	}
+	c.CompleteHooks = append(([]func(*Outcome))(nil), r.CompleteHooks...)
+	c.RetryHooks = append(([]func(attempt int, delay time.Duration, reason RetryReason) bool)(nil), r.RetryHooks...)
+	c.CacheHooks = append(([]func(event CacheEvent, key string))(nil), r.CacheHooks...)
+	c.Validators = append([]Validator(nil), r.Validators...)
+	return &c
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
 func (r *Request) reset(payload *Payload) {
 	r.Headers = nil
+	r.MultiHeaders = nil
 	r.Cookies = nil
+	r.Trailers = nil
+	r.RawHeaders = nil
+	r.Query = nil
+	r.Close = false
 
 	if payload.closer != nil {
 		payload.closer.Close()
 	}
 }
 
+// httpClient return the client used for the call, the client is copied
+// when the request overrides the transport or the redirect policy.
+func (r *Request) httpClient() (*http.Client, error) {
+	_, denyPlaintext := plaintextPolicies.Load(weak.Make(r.Client))
+	if r.Transport == nil && r.TLSConfig == nil && r.ProxyAuth == nil && r.LocalAddr == "" && r.LocalInterface == "" &&
+		len(r.RawHeaders) == 0 && r.HSTS == nil && r.RedirectAuth && len(r.Validators) == 0 && !denyPlaintext {
+		return r.Client, nil
+	}
+
+	client := *r.Client
+	if !r.RedirectAuth {
+		client.CheckRedirect = stripSensitiveHeaders(client.CheckRedirect)
+	}
+	if r.HSTS != nil {
+		client.CheckRedirect = r.HSTS.checkRedirect(client.CheckRedirect)
+	}
+	if len(r.Validators) > 0 {
+		client.CheckRedirect = r.validateRedirect(client.CheckRedirect)
+	}
+	if denyPlaintext {
+		client.CheckRedirect = r.denyPlaintextRedirect(client.CheckRedirect)
+	}
+	if r.Transport != nil {
+		client.Transport = r.Transport
+	}
+	if r.TLSConfig != nil || r.ProxyAuth != nil || r.LocalAddr != "" || r.LocalInterface != "" {
+		rt, err := r.derivedRoundTripper(client.Transport)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = rt
+	}
+	if len(r.RawHeaders) > 0 {
+		rt, err := newRawTransport(client.Transport, r.RawHeaders)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = rt
+	}
+	return &client, nil
+}
+
+// rewindRequest return a copy of req with a fresh body for resending
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, errors.New("request body cannot be rewound")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
+
+// NewURL panics if query is not a supported type, for compatibility,
+// use NewURLE to get an error.
 func NewURL(u string, query interface{}) string {
+	s, err := NewURLE(u, query)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewURLE appends query (url.Values, map[string]string or map[string][]string) to u
+func NewURLE(u string, query interface{}) (string, error) {
 	if query == nil {
-		return u
+		return u, nil
 	}
 
-	qs := newValues(query)
+	qs, err := newValues(query)
+	if err != nil {
+		return "", err
+	}
 	if strings.Contains(u, "?") {
-		return u + "&" + qs.Encode()
+		return u + "&" + qs.Encode(), nil
 	}
-	return u + "?" + qs.Encode()
+	return u + "?" + qs.Encode(), nil
 }
@@ -0,0 +1,81 @@
+package curl
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+// DNSResolver resolves host names for dialing, *net.Resolver implements it.
+// Custom implementations can be used for DNS-over-HTTPS, static hosts, ...
+type DNSResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// NewDNSResolver return a resolver which queries the given DNS servers ("ip:port") in order
+func NewDNSResolver(servers ...string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			var err error
+			for _, server := range servers {
+				var conn net.Conn
+				if conn, err = d.DialContext(ctx, network, server); err == nil {
+					return conn, nil
+				}
+			}
+			if err == nil {
+				err = errors.New("no dns server")
+			}
+			return nil, err
+		},
+	}
+}
+
+// resolveDialContext resolves the host by resolver and dials the addresses in order
+func resolveDialContext(dial dialFunc, resolver DNSResolver, balancer Balancer) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if !strings.HasPrefix(network, "tcp") && !strings.HasPrefix(network, "udp") {
+			return dial(ctx, network, addr)
+		}
+
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		ipaddrs, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ipaddrs) == 0 {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+
+		addrs := make([]string, 0, len(ipaddrs))
+		for _, ipaddr := range ipaddrs {
+			if matchIPFamily(network, ipaddr.IP) {
+				addrs = append(addrs, net.JoinHostPort(ipaddr.String(), port))
+			}
+		}
+		if len(addrs) == 0 {
+			return nil, &net.DNSError{Err: "no address for " + network, Name: host, IsNotFound: true}
+		}
+		return dialAddrs(ctx, dial, network, addrs, balancer)
+	}
+}
+
+func matchIPFamily(network string, ip net.IP) bool {
+	switch network[len(network)-1] {
+	case '4':
+		return ip.To4() != nil
+	case '6':
+		return ip.To4() == nil
+	}
+	return true
+}
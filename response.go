@@ -1,61 +1,170 @@
 package curl
 
 import (
-	"compress/gzip"
-	"compress/zlib"
-	"encoding/json"
+	"bytes"
+	"errors"
 	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // Response ...
 type Response struct {
 	*http.Response
-	bytes []byte
+	bytes    []byte
+	limit    int64
+	reused   bool
+	attempts int
+	tracker  *connTracker
+	cached   bool
 }
 
+// ErrBodyTooLarge is returned when the body exceeds Request.BufferLimit
+var ErrBodyTooLarge = errors.New("response body too large")
+
 // Content return Response Body as []byte
 func (resp *Response) Bytes() ([]byte, error) {
 	if resp.bytes != nil {
 		return resp.bytes, nil
 	}
 
-	var reader io.ReadCloser
-	var err error
-	switch resp.Header.Get("Content-Encoding") {
-	case "gzip":
-		if reader, err = gzip.NewReader(resp.Body); err != nil {
-			return nil, err
-		}
-	case "deflate":
-		if reader, err = zlib.NewReader(resp.Body); err != nil {
-			return nil, err
-		}
-	default:
-		reader = resp.Body
+	defer resp.Body.Close()
+	reader, err := resp.decodedBody()
+	if err != nil {
+		return nil, err
 	}
-
 	defer reader.Close()
+
+	if resp.limit > 0 {
+		reader = ioutil.NopCloser(io.LimitReader(reader, resp.limit+1))
+	}
 	b, err := ioutil.ReadAll(reader)
 	if err != nil {
 		return nil, err
 	}
+	if resp.limit > 0 && int64(len(b)) > resp.limit {
+		return nil, ErrBodyTooLarge
+	}
 
 	resp.bytes = b
 	return b, nil
 }
 
+// decodedBody return the Body reader decoding Content-Encoding
+func (resp *Response) decodedBody() (io.ReadCloser, error) {
+	return decodeContent(resp.Body, resp.Header.Get("Content-Encoding"))
+}
+
+// WriteTo copies the (decoded) Response Body to w
+func (resp *Response) WriteTo(w io.Writer) (int64, error) {
+	if resp.bytes != nil {
+		n, err := w.Write(resp.bytes)
+		return int64(n), err
+	}
+
+	defer resp.Body.Close()
+	reader, err := resp.decodedBody()
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	buf := copyBufferPool.Get().(*[]byte)
+	defer copyBufferPool.Put(buf)
+	return io.CopyBuffer(w, reader, *buf)
+}
+
 // Text return Response Body as string
 func (resp *Response) Text() (string, error) {
 	b, err := resp.Bytes()
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 	return string(b), nil
 }
 
+// Reader return a new reader of the buffered Response Body
+func (resp *Response) Reader() (io.Reader, error) {
+	b, err := resp.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// buffer reads the (decoded) body in memory, Body is replaced by the buffered content
+func (resp *Response) buffer(limit int64) error {
+	resp.limit = limit
+	b, err := resp.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if resp.Header.Get("Content-Encoding") != "" {
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = int64(len(b))
+		resp.Uncompressed = true
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return nil
+}
+
+// ConnReused return whether the response was received on a reused connection
+func (resp *Response) ConnReused() bool {
+	return resp.reused
+}
+
+// Trailers return Response trailers, the body is read to get them
+func (resp *Response) Trailers() (http.Header, error) {
+	if _, err := resp.Bytes(); err != nil {
+		return nil, err
+	}
+	return resp.Trailer, nil
+}
+
+// BytesRead return the bytes received on the wire for the response (headers,
+// body and TLS overhead), final once the body is consumed. It is exact for
+// HTTP/1.x with a client created by NewClient, it is -1 if unavailable:
+// cached responses, other clients and HTTP/2 or HTTP/3, where the connection
+// is shared by concurrent requests.
+func (resp *Response) BytesRead() int64 {
+	read, _ := resp.byteCounts()
+	return read
+}
+
+// BytesWritten return the bytes sent on the wire for the request, see BytesRead
+func (resp *Response) BytesWritten() int64 {
+	_, written := resp.byteCounts()
+	return written
+}
+
+func (resp *Response) byteCounts() (int64, int64) {
+	if resp.tracker == nil || resp.ProtoMajor != 1 {
+		return -1, -1
+	}
+	read, written, ok := resp.tracker.byteCounts()
+	if !ok {
+		return -1, -1
+	}
+	return read, written
+}
+
+// Attempts return the number of attempts made for the response
+func (resp *Response) Attempts() int {
+	return resp.attempts
+}
+
+// discard drains and closes the body so the connection can be reused
+func (resp *Response) discard() {
+	io.CopyN(ioutil.Discard, resp.Body, 4096)
+	resp.Body.Close()
+}
+
 // OK check Response StatusCode < 400 ?
 func (resp *Response) OK() bool {
 	return resp.StatusCode < 400
@@ -68,13 +177,13 @@ func (resp *Response) JSON() (interface{}, error) {
 	return v, err
 }
 
-// JSONUnmarshal unmarshal Response Body
+// JSONUnmarshal unmarshal Response Body, by the codec of "application/json"
 func (resp *Response) JSONUnmarshal(data interface{}) error {
 	b, err := resp.Bytes()
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(b, data)
+	return CodecFor("application/json").Decode(b, data)
 }
 
 // RequestURL return finally request url
@@ -91,3 +200,34 @@ func (resp *Response) RequestURL() (*url.URL, error) {
 	}
 	return u, nil
 }
+
+// Multipart return a reader over the parts of a multipart (multipart/mixed,
+// multipart/form-data, ...) response, each part has its own headers and body,
+// the Body must be closed when done.
+//
+//	mr, err := resp.Multipart()
+//	for {
+//		part, err := mr.NextPart()
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//	}
+func (resp *Response) Multipart() (*multipart.Reader, error) {
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil, errors.New("response is not multipart: " + mediaType)
+	}
+
+	if resp.bytes != nil {
+		return multipart.NewReader(bytes.NewReader(resp.bytes), params["boundary"]), nil
+	}
+	reader, err := resp.decodedBody()
+	if err != nil {
+		return nil, err
+	}
+	return multipart.NewReader(reader, params["boundary"]), nil
+}
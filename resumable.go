@@ -0,0 +1,191 @@
+package curl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrUploadSessionGone is returned when the resumable upload session expired
+var ErrUploadSessionGone = errors.New("upload session not found")
+
+// ResumableUpload uploads a file by a resumable session, as Google Drive and
+// Cloud Storage: a session is started on Endpoint, the chunks are PUT to the
+// session URL with Content-Range and acknowledged by "308 Resume Incomplete"
+// until the final response. A failed chunk resumes from the range received
+// by the server.
+//
+//	upload := &curl.ResumableUpload{
+//		Endpoint: "https://storage.googleapis.com/upload/storage/v1/b/bucket/o?uploadType=resumable&name=video.mp4",
+//		ContentType: "video/mp4",
+//	}
+//	resp, err := upload.Upload(ctx, f, size)
+type ResumableUpload struct {
+	Request     *Request    // cloned for the calls, a new request if nil
+	Endpoint    string      // starts the session
+	Method      string      // of the session start, default "POST"
+	Metadata    interface{} // body of the session start, e.g. the object metadata
+	ContentType string      // of the uploaded content, X-Upload-Content-Type
+	URL         string      // of the session, started if empty
+	ChunkSize   int64       // multiple of 256KB, default 8MB
+	Retries     int         // resumes after failed chunks, default 3
+	OnProgress  func(offset, size int64)
+}
+
+// Upload sends the size bytes of r, it return the final response of the session
+func (u *ResumableUpload) Upload(ctx context.Context, r io.ReaderAt, size int64) (*Response, error) {
+	offset := int64(0)
+	if u.URL == "" {
+		if err := u.Start(ctx, size); err != nil {
+			return nil, err
+		}
+	} else {
+		var resp *Response
+		var err error
+		if offset, resp, err = u.Status(ctx, size); err != nil || resp != nil {
+			return resp, err
+		}
+	}
+
+	chunkSize := u.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 8 << 20
+	}
+	retries := u.Retries
+	if retries == 0 {
+		retries = 3
+	}
+
+	buf := make([]byte, chunkSize)
+	failures := 0
+	for {
+		if u.OnProgress != nil {
+			u.OnProgress(offset, size)
+		}
+		n := chunkSize
+		if size-offset < n {
+			n = size - offset
+		}
+		if _, err := r.ReadAt(buf[:n], offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		next, resp, err := u.put(ctx, offset, buf[:n], size)
+		if err == nil {
+			if resp != nil {
+				if u.OnProgress != nil {
+					u.OnProgress(size, size)
+				}
+				return resp, nil
+			}
+			if n == 0 && next == offset {
+				return nil, fmt.Errorf("upload session incomplete at %d of %d bytes", offset, size)
+			}
+			offset, failures = next, 0
+			continue
+		}
+		if ctx.Err() != nil || errors.Is(err, ErrUploadSessionGone) || failures >= retries {
+			return nil, err
+		}
+		failures++
+		// resume from the range received by the server
+		if offset, resp, err = u.Status(ctx, size); err != nil || resp != nil {
+			return resp, err
+		}
+	}
+}
+
+func (u *ResumableUpload) request() *Request {
+	r := NewRequest(nil)
+	if u.Request != nil {
+		r = u.Request.clone()
+	}
+	return r
+}
+
+// Start starts the upload session of size bytes, it sets URL
+func (u *ResumableUpload) Start(ctx context.Context, size int64) error {
+	method := u.Method
+	if method == "" {
+		method = "POST"
+	}
+	r := u.request().WithHeader("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+	if u.ContentType != "" {
+		r.WithHeader("X-Upload-Content-Type", u.ContentType)
+	}
+	resp, err := r.CallContext(ctx, method, u.Endpoint, u.Metadata)
+	if err != nil {
+		return err
+	}
+	resp.discard()
+	if !resp.OK() {
+		return &StatusError{resp.StatusCode, resp.Status}
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return fmt.Errorf("upload session without location: %w", err)
+	}
+	u.URL = location.String()
+	return nil
+}
+
+// Status return the bytes of the upload received by the server, or the
+// final response if the upload is complete
+func (u *ResumableUpload) Status(ctx context.Context, size int64) (int64, *Response, error) {
+	resp, err := u.request().
+		WithHeader("Content-Range", "bytes */"+strconv.FormatInt(size, 10)).
+		CallContext(ctx, "PUT", u.URL, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	return u.progress(resp)
+}
+
+// put sends the chunk at offset, it return the new offset or the final response
+func (u *ResumableUpload) put(ctx context.Context, offset int64, chunk []byte, size int64) (int64, *Response, error) {
+	contentRange := "bytes */" + strconv.FormatInt(size, 10)
+	if len(chunk) > 0 {
+		contentRange = fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(chunk))-1, size)
+	}
+	resp, err := u.request().
+		WithHeader("Content-Range", contentRange).
+		CallContext(ctx, "PUT", u.URL, chunk)
+	if err != nil {
+		return 0, nil, err
+	}
+	return u.progress(resp)
+}
+
+// progress parses the Range of a 308 response, it return resp if the upload is complete
+func (u *ResumableUpload) progress(resp *Response) (int64, *Response, error) {
+	switch {
+	case resp.StatusCode == http.StatusPermanentRedirect:
+		resp.discard()
+		// "bytes=0-42", no Range if nothing was received
+		rng := resp.Header.Get("Range")
+		if rng == "" {
+			return 0, nil, nil
+		}
+		i := strings.LastIndexByte(rng, '-')
+		if i < 0 {
+			return 0, nil, fmt.Errorf("invalid upload range: %s", rng)
+		}
+		end, err := strconv.ParseInt(rng[i+1:], 10, 64)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid upload range: %s", rng)
+		}
+		return end + 1, nil, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		resp.discard()
+		return 0, nil, fmt.Errorf("%w: %s", ErrUploadSessionGone, resp.Request.URL)
+	case !resp.OK():
+		resp.discard()
+		return 0, nil, &StatusError{resp.StatusCode, resp.Status}
+	}
+	return 0, resp, nil
+}
@@ -0,0 +1,227 @@
+package curl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryReason is the failure class of a retried attempt
+type RetryReason int
+
+const (
+	// RetryConnectError is a failure before the request was sent (DNS, dial, TLS),
+	// it is safe to retry for all methods.
+	RetryConnectError RetryReason = iota + 1
+	// RetryConnReset is a connection failure after the request was sent
+	RetryConnReset
+	// RetryTimeout is a timeout after the request was sent
+	RetryTimeout
+	// RetryStatus is a response received with a retryable status code
+	RetryStatus
+)
+
+func (r RetryReason) String() string {
+	switch r {
+	case RetryConnectError:
+		return "connect error"
+	case RetryConnReset:
+		return "connection reset"
+	case RetryTimeout:
+		return "timeout"
+	case RetryStatus:
+		return "retryable status"
+	}
+	return "unknown"
+}
+
+// RetryPolicy controls when and how a failed call is retried.
+//
+// Only idempotent methods are retried once the request was sent, other
+// methods are retried when listed in Methods or when the request carries
+// an Idempotency-Key header.
+type RetryPolicy struct {
+	MaxAttempts    int           // including the first one
+	Backoff        time.Duration // base of the exponential backoff, default 100ms
+	MaxBackoff     time.Duration // default 10s
+	Statuses       []int         // default 429, 502, 503, 504
+	Methods        []string      // non-idempotent methods allowed to be retried
+	MaxElapsed     time.Duration // total time of all attempts and backoffs, 0 for unlimited
+	AttemptTimeout time.Duration // deadline of each attempt, 0 for none
+}
+
+var defaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+var idempotentMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"OPTIONS": true,
+	"TRACE":   true,
+	"PUT":     true,
+	"DELETE":  true,
+
+	// WebDAV
+	"PROPFIND": true,
+	"REPORT":   true,
+}
+
+// Classify return the failure class of an attempt, 0 if it should not be retried
+func (p *RetryPolicy) Classify(req *http.Request, resp *Response, err error, wrote bool) RetryReason {
+	if err != nil {
+		// refused by the policy, not a failure
+		if req.Context().Err() != nil || errors.Is(err, ErrPlaintext) {
+			return 0
+		}
+		if !wrote {
+			return RetryConnectError
+		}
+		var nerr net.Error
+		if errors.As(err, &nerr) && nerr.Timeout() {
+			return RetryTimeout
+		}
+		return RetryConnReset
+	}
+
+	statuses := p.Statuses
+	if statuses == nil {
+		statuses = defaultRetryStatuses
+	}
+	for _, status := range statuses {
+		if resp.StatusCode == status {
+			return RetryStatus
+		}
+	}
+	return 0
+}
+
+// retryable return whether req can be resent after a failure of reason
+func (p *RetryPolicy) retryable(req *http.Request, reason RetryReason) bool {
+	if reason == RetryConnectError || idempotentMethods[req.Method] {
+		return true
+	}
+	if req.Header.Get(IdempotencyKeyHeader) != "" {
+		return true
+	}
+	return containsString(p.Methods, req.Method)
+}
+
+// retry return the delay before the next attempt and the failure class,
+// false if no retry or if the next attempt would start after deadline.
+func (p *RetryPolicy) retry(attempt int, req *http.Request, resp *Response, err error, wrote bool, deadline time.Time) (time.Duration, RetryReason, bool) {
+	if p == nil || attempt >= p.MaxAttempts {
+		return 0, 0, false
+	}
+	reason := p.Classify(req, resp, err, wrote)
+	if reason == 0 || !p.retryable(req, reason) {
+		return 0, 0, false
+	}
+	delay := p.delay(attempt, resp)
+	if !deadline.IsZero() && !time.Now().Add(delay).Before(deadline) {
+		return 0, 0, false
+	}
+	return delay, reason, true
+}
+
+// deadline return the end of the retry budget started at start,
+// the earliest of MaxElapsed and the deadline of ctx, zero if unlimited.
+func (p *RetryPolicy) deadline(ctx context.Context, start time.Time) time.Time {
+	var deadline time.Time
+	if p != nil && p.MaxElapsed > 0 {
+		deadline = start.Add(p.MaxElapsed)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	return deadline
+}
+
+// attemptContext return the context of an attempt, bounded by AttemptTimeout
+// and by the budget deadline.
+func (p *RetryPolicy) attemptContext(ctx context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	if p == nil || (p.AttemptTimeout <= 0 && p.MaxElapsed <= 0) {
+		return ctx, func() {}
+	}
+	if p.AttemptTimeout > 0 {
+		if d := time.Now().Add(p.AttemptTimeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	if deadline.IsZero() {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// cancelBody cancels the attempt context once the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// delay is an exponential backoff with jitter, or the Retry-After of the response
+func (p *RetryPolicy) delay(attempt int, resp *Response) time.Duration {
+	maxBackoff := durationOrDefault(p.MaxBackoff, 10*time.Second)
+
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if d > maxBackoff {
+				d = maxBackoff
+			}
+			return d
+		}
+	}
+
+	d := durationOrDefault(p.Backoff, 100*time.Millisecond) << uint(attempt-1)
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	// full jitter in [d/2, d)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,134 @@
+package curl
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSecretNotFound is returned by the providers for unknown secrets
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider return the current value of a secret, e.g. from Vault, AWS
+// Secrets Manager or mounted files. It is called for every call, so the
+// rotated secrets are used without restarting.
+type SecretProvider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// SecretFunc is a SecretProvider function
+type SecretFunc func(ctx context.Context, name string) (string, error)
+
+func (f SecretFunc) Get(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// FileSecrets reads the secret name from the file Dir/name, trimmed, e.g.
+// the secrets mounted by Kubernetes.
+type FileSecrets struct {
+	Dir string
+}
+
+func (s *FileSecrets) Get(ctx context.Context, name string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.Dir, filepath.Clean("/"+name)))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// EnvSecrets reads the secret name from the environment variable Prefix+name
+type EnvSecrets struct {
+	Prefix string
+}
+
+func (s *EnvSecrets) Get(ctx context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(s.Prefix + name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, s.Prefix+name)
+	}
+	return v, nil
+}
+
+// CachedSecrets keeps the secrets of Provider for TTL, for the remote providers
+type CachedSecrets struct {
+	Provider SecretProvider
+	TTL      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+func NewCachedSecrets(provider SecretProvider, ttl time.Duration) *CachedSecrets {
+	return &CachedSecrets{Provider: provider, TTL: ttl, entries: make(map[string]cachedSecret)}
+}
+
+func (s *CachedSecrets) Get(ctx context.Context, name string) (string, error) {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	s.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.value, nil
+	}
+
+	value, err := s.Provider.Get(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.entries[name] = cachedSecret{value, time.Now().Add(s.TTL)}
+	s.mu.Unlock()
+	return value, nil
+}
+
+// SecretTokenAuth sends the secret Name as "Authorization: Scheme <secret>"
+//
+//	req.Auth = &curl.SecretTokenAuth{Provider: &curl.FileSecrets{Dir: "/var/run/secrets/api"}, Name: "token"}
+type SecretTokenAuth struct {
+	Provider SecretProvider
+	Name     string
+	Scheme   string // default "Bearer"
+}
+
+func (a *SecretTokenAuth) AuthHeader(ctx context.Context) (string, error) {
+	token, err := a.Provider.Get(ctx, a.Name)
+	if err != nil {
+		return "", err
+	}
+	scheme := a.Scheme
+	if scheme == "" {
+		scheme = "Bearer"
+	}
+	return scheme + " " + token, nil
+}
+
+// SecretBasicAuth sends Username and the secret PasswordName as basic auth
+type SecretBasicAuth struct {
+	Provider     SecretProvider
+	Username     string
+	PasswordName string
+}
+
+func (a *SecretBasicAuth) AuthHeader(ctx context.Context) (string, error) {
+	password, err := a.Provider.Get(ctx, a.PasswordName)
+	if err != nil {
+		return "", err
+	}
+	auth := a.Username + ":" + password
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth)), nil
+}
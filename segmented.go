@@ -0,0 +1,107 @@
+package curl
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// DownloadSegmented saves the content of url into filename using parallel
+// range requests, it falls back to a single stream download when the server
+// does not support ranges.
+func (r *Request) DownloadSegmented(url string, filename string, segments int) (int64, error) {
+	// the per call options are used by all the segment requests
+	base := r.clone()
+	defer r.reset(emptyPayload)
+
+	// the sizes of the probe and of the ranges must be of the same encoding
+	head, err := base.clone().WithHeader("Accept-Encoding", "identity").Head(url)
+	if err != nil {
+		return 0, err
+	}
+	head.discard()
+
+	size := head.ContentLength
+	if segments <= 1 || !head.OK() || head.Header.Get("Accept-Ranges") != "bytes" || size <= 0 {
+		return base.DownloadFile(url, filename, nil)
+	}
+	if int64(segments) > size {
+		segments = int(size)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return 0, err
+	}
+	if err = f.Truncate(size); err != nil {
+		f.Close()
+		os.Remove(filename)
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, segments)
+	chunk := size / int64(segments)
+	for i := 0; i < segments; i++ {
+		start := int64(i) * chunk
+		end := start + chunk - 1
+		if i == segments-1 {
+			end = size - 1
+		}
+
+		wg.Add(1)
+		go func(i int, start, end int64) {
+			defer wg.Done()
+			errs[i] = base.clone().downloadRange(url, f, start, end, size)
+		}(i, start, end)
+	}
+	wg.Wait()
+
+	err = f.Close()
+	for _, e := range errs {
+		if e != nil {
+			err = e
+			break
+		}
+	}
+	if err != nil {
+		os.Remove(filename)
+		return 0, err
+	}
+	return size, nil
+}
+
+// downloadRange writes the bytes [start, end] of the size bytes of url at the
+// same offset of f
+func (r *Request) downloadRange(url string, f *os.File, start, end, size int64) error {
+	r.WithHeader("Range", "bytes="+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10))
+	r.WithHeader("Accept-Encoding", "identity")
+
+	resp, err := r.Get(url)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.discard()
+		return fmt.Errorf("range request not satisfied: %s", resp.Status)
+	}
+	// the server could send another range, or the content could have changed
+	want := fmt.Sprintf("bytes %d-%d/%d", start, end, size)
+	if got := resp.Header.Get("Content-Range"); got != want {
+		resp.discard()
+		return fmt.Errorf("range %d-%d: unexpected content range %q", start, end, got)
+	}
+
+	w := io.NewOffsetWriter(f, start)
+	n, err := resp.WriteTo(w)
+	if err != nil {
+		return err
+	}
+	if n != end-start+1 {
+		return fmt.Errorf("range %d-%d: short read %d bytes", start, end, n)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package curl
+
+import (
+	"io"
+	"net"
+)
+
+// readFrom copies r into conn by the ReadFrom of conn if any, so a file
+// payload is sent by sendfile/splice on a plain TCP connection instead of
+// being copied through user space buffers. The wrappers of the connections
+// (statsConn, balancedConn) implement io.ReaderFrom with it, the transport
+// passes the *os.File of the payload to ReadFrom.
+func readFrom(conn net.Conn, r io.Reader) (int64, error) {
+	if rf, ok := conn.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{conn}, r)
+}
+
+// writerOnly hides the ReadFrom of the wrapper from io.Copy
+type writerOnly struct {
+	io.Writer
+}
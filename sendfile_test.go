@@ -0,0 +1,76 @@
+package curl
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchmarkFileUpload sends a 16MB file over loopback TCP by copy
+func benchmarkFileUpload(b *testing.B, copy func(conn net.Conn, f *os.File) (int64, error)) {
+	const size = 16 << 20
+	filename := filepath.Join(b.TempDir(), "payload")
+	if err := ioutil.WriteFile(filename, make([]byte, size), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(ioutil.Discard, c)
+				c.Close()
+			}()
+		}
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+	sc := &statsConn{Conn: conn}
+
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, err := os.Open(filename)
+		if err != nil {
+			b.Fatal(err)
+		}
+		n, err := copy(sc, f)
+		f.Close()
+		if err != nil || n != size {
+			b.Fatalf("copied %d bytes: %v", n, err)
+		}
+	}
+}
+
+func BenchmarkFileUploadReadFrom(b *testing.B) {
+	benchmarkFileUpload(b, func(conn net.Conn, f *os.File) (int64, error) {
+		return conn.(io.ReaderFrom).ReadFrom(f)
+	})
+}
+
+func BenchmarkFileUploadCopy(b *testing.B) {
+	benchmarkFileUpload(b, func(conn net.Conn, f *os.File) (int64, error) {
+		return io.Copy(writerOnly{conn}, readerOnly{f})
+	})
+}
+
+// readerOnly hides the WriteTo of *os.File from io.Copy
+type readerOnly struct {
+	io.Reader
+}
@@ -0,0 +1,133 @@
+package curl
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// Shadow duplicates a fraction of the calls to a secondary base URL, e.g. to
+// test a new backend with the production traffic. The shadow calls are sent
+// in background without retries, their responses are discarded and their
+// errors are sampled to Logger.
+//
+// The requests are copied with their headers, including the authorization.
+// A call with a body which cannot be rewound (an io.Reader) is not shadowed.
+//
+//	shadow, _ := curl.NewShadow("https://canary.example.com/api", 0.1)
+//	req := curl.NewRequest(client).WithShadow(shadow)
+type Shadow struct {
+	Fraction    float64       // of the calls shadowed, 0 to 1
+	Client      *http.Client  // the client of the request if nil
+	Timeout     time.Duration // of a shadow call, default 30s
+	MaxInFlight int           // shadow calls are dropped above, default 100
+	ErrorSample float64       // of the errors logged, 0 to 1
+	Logger      Logger
+
+	base     *url.URL
+	inFlight int32
+	sent     int64
+	dropped  int64
+	errors   int64
+}
+
+// ShadowStats are the counters of the shadow calls
+type ShadowStats struct {
+	Sent    int64
+	Dropped int64 // above MaxInFlight or not rewindable
+	Errors  int64 // failed calls and 5xx responses
+}
+
+func NewShadow(baseURL string, fraction float64) (*Shadow, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Shadow{Fraction: fraction, base: u}, nil
+}
+
+// WithShadow duplicates the calls to the shadow, which can be shared between requests
+func (r *Request) WithShadow(shadow *Shadow) *Request {
+	r.Shadow = shadow
+	return r
+}
+
+func (s *Shadow) Stats() ShadowStats {
+	return ShadowStats{
+		Sent:    atomic.LoadInt64(&s.sent),
+		Dropped: atomic.LoadInt64(&s.dropped),
+		Errors:  atomic.LoadInt64(&s.errors),
+	}
+}
+
+// send duplicates req to the shadow base URL in background, if sampled,
+// the logged URLs are redacted by x. The url is checked by plaintext when
+// sent by client, the client of the call.
+func (s *Shadow) send(client *http.Client, req *http.Request, merge QueryMerge, x *Redaction, plaintext func(*url.URL) error) {
+	if s.Fraction <= 0 || rand.Float64() >= s.Fraction {
+		return
+	}
+	maxInFlight := s.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = 100
+	}
+	if atomic.AddInt32(&s.inFlight, 1) > int32(maxInFlight) {
+		atomic.AddInt32(&s.inFlight, -1)
+		atomic.AddInt64(&s.dropped, 1)
+		return
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	// the shadow call outlives the call, but not its timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), timeout)
+	shadow, err := rewindRequest(req.WithContext(ctx))
+	if err == nil {
+		shadow.URL, err = resolveEndpoint(s.base, req.URL, merge)
+	}
+	if err == nil && s.Client == nil {
+		err = plaintext(shadow.URL)
+	}
+	if err != nil {
+		cancel()
+		atomic.AddInt32(&s.inFlight, -1)
+		atomic.AddInt64(&s.dropped, 1)
+		return
+	}
+	shadow.Host = ""
+	shadow.RequestURI = ""
+	if s.Client != nil {
+		client = s.Client
+	}
+
+	atomic.AddInt64(&s.sent, 1)
+	go func() {
+		defer atomic.AddInt32(&s.inFlight, -1)
+		defer cancel()
+
+		resp, err := client.Do(shadow)
+		if err == nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				return
+			}
+		}
+		atomic.AddInt64(&s.errors, 1)
+		if s.Logger != nil && rand.Float64() < s.ErrorSample {
+			if err != nil {
+				x.redactError(err)
+				s.Logger.Printf("shadow request: %s %s: %v", shadow.Method, x.URL(shadow.URL), err)
+			} else {
+				s.Logger.Printf("shadow request: %s %s: status %d", shadow.Method, x.URL(shadow.URL), resp.StatusCode)
+			}
+		}
+	}()
+}
@@ -0,0 +1,233 @@
+package curl
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// requestSigner is an auth signing the prepared request, e.g. AWSSigV4
+type requestSigner interface {
+	Sign(req *http.Request) error
+}
+
+// AWSSigV4 signs the requests with the AWS Signature Version 4, as Request.Auth.
+// The body is hashed if it can be rewound, otherwise it is sent unsigned
+// (supported by S3). The requests to Endpoints are signed before the endpoint
+// is resolved, so they must not be used together.
+//
+//	req.Auth = &curl.AWSSigV4{Credentials: curl.NewIMDSCredentials(), Region: "us-east-1", Service: "s3"}
+type AWSSigV4 struct {
+	Credentials AWSCredentialsProvider
+	Region      string
+	Service     string
+	Streaming   bool // signs the body by chunks (aws-chunked), it requires the content length
+	ChunkSize   int  // of the streaming signature, default 64KB
+}
+
+func (s *AWSSigV4) Sign(req *http.Request) error {
+	creds, err := s.Credentials.Credentials(req.Context())
+	if err != nil {
+		return err
+	}
+
+	if s.Streaming && req.Body != nil && req.Body != http.NoBody {
+		return s.signStreaming(req, creds)
+	}
+
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		if payloadHash, err = hashPayload(req); err != nil {
+			return err
+		}
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	s.sign(req, creds, time.Now().UTC(), payloadHash)
+	return nil
+}
+
+// sign sets the Authorization of req at t, it return the signature
+func (s *AWSSigV4) sign(req *http.Request, creds *AWSCredentials, t time.Time, payloadHash string) string {
+	amzDate := t.Format(sigV4TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	signedHeaders, canonicalHeaders := canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL, s.Service == "s3"),
+		canonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := s.scope(t)
+	signature := hex.EncodeToString(hmacSHA256(s.signingKey(creds, t), sigV4StringToSign(amzDate, scope, sha256Hex([]byte(canonicalRequest)))))
+	req.Header.Set("Authorization", sigV4Algorithm+" Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return signature
+}
+
+func (s *AWSSigV4) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+func (s *AWSSigV4) signingKey(creds *AWSCredentials, t time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func sigV4StringToSign(amzDate, scope, hash string) string {
+	return sigV4Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hash
+}
+
+// hashPayload return the hex SHA-256 of the body, UNSIGNED-PAYLOAD if it cannot be rewound
+func hashPayload(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return sha256Hex(nil), nil
+	}
+	if req.GetBody == nil {
+		return unsignedPayload, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalHeaders signs host, the content headers (the length included if
+// known) and the x-amz-* headers
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	if req.ContentLength > 0 {
+		values["content-length"] = strconv.FormatInt(req.ContentLength, 10)
+	}
+	for name, vs := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "content-") || strings.HasPrefix(lower, "x-amz-") {
+			trimmed := make([]string, len(vs))
+			for i, v := range vs {
+				trimmed[i] = strings.Join(strings.Fields(v), " ")
+			}
+			values[lower] = strings.Join(trimmed, ",")
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		buf.WriteString(name + ":" + values[name] + "\n")
+	}
+	return strings.Join(names, ";"), buf.String()
+}
+
+// canonicalPath return the URI-encoded path, encoded twice except for S3
+func canonicalPath(u *url.URL, s3 bool) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if decoded, err := url.PathUnescape(segment); err == nil {
+			segment = decoded
+		}
+		segment = awsEscape(segment)
+		if !s3 {
+			segment = awsEscape(segment)
+		}
+		segments[i] = segment
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery return the query sorted by key and value, URI-encoded. A
+// "+" is a plus sign, not a space as in url.ParseQuery.
+func canonicalQuery(u *url.URL) string {
+	query := make(url.Values)
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		if k, err := url.PathUnescape(key); err == nil {
+			key = k
+		}
+		if v, err := url.PathUnescape(value); err == nil {
+			value = v
+		}
+		query[key] = append(query[key], value)
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, awsEscape(key)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes all the bytes except the unreserved characters
+func awsEscape(s string) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			buf.WriteByte(c)
+		} else {
+			buf.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return buf.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
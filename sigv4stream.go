@@ -0,0 +1,133 @@
+package curl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	streamingPayload    = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingAlgorithm  = "AWS4-HMAC-SHA256-PAYLOAD"
+	defaultSigChunkSize = 64 << 10
+)
+
+// signStreaming signs the headers with the streaming payload and replaces
+// the body by its aws-chunked encoding, each chunk is signed with the
+// signature of the previous one, so the body is never buffered
+func (s *AWSSigV4) signStreaming(req *http.Request, creds *AWSCredentials) error {
+	if req.ContentLength <= 0 {
+		return errors.New("streaming signature requires the content length")
+	}
+	chunkSize := s.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultSigChunkSize
+	}
+
+	decodedLength := req.ContentLength
+	req.Header.Set("X-Amz-Content-Sha256", streamingPayload)
+	req.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(decodedLength, 10))
+	if encoding := req.Header.Get("Content-Encoding"); encoding != "" {
+		req.Header.Set("Content-Encoding", "aws-chunked,"+encoding)
+	} else {
+		req.Header.Set("Content-Encoding", "aws-chunked")
+	}
+	req.ContentLength = awsChunkedLength(decodedLength, chunkSize)
+
+	t := time.Now().UTC()
+	seed := s.sign(req, creds, t, streamingPayload)
+	newBody := func(body io.ReadCloser) io.ReadCloser {
+		return &awsChunkedBody{
+			body:      body,
+			chunkSize: chunkSize,
+			key:       s.signingKey(creds, t),
+			amzDate:   t.Format(sigV4TimeFormat),
+			scope:     s.scope(t),
+			signature: seed,
+		}
+	}
+
+	req.Body = newBody(req.Body)
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return newBody(body), nil
+		}
+	}
+	return nil
+}
+
+// awsChunkedLength return the encoded length of a body of n bytes
+func awsChunkedLength(n int64, chunkSize int) int64 {
+	frame := func(size int64) int64 {
+		// hex size + ";chunk-signature=" + signature + CRLF + data + CRLF
+		return int64(len(strconv.FormatInt(size, 16))) + 17 + 64 + 2 + size + 2
+	}
+	full := n / int64(chunkSize)
+	length := full * frame(int64(chunkSize))
+	if rest := n % int64(chunkSize); rest > 0 {
+		length += frame(rest)
+	}
+	return length + frame(0)
+}
+
+// awsChunkedBody encodes body in signed chunks
+type awsChunkedBody struct {
+	body      io.ReadCloser
+	chunkSize int
+	key       []byte
+	amzDate   string
+	scope     string
+	signature string // of the previous chunk
+
+	buf  bytes.Buffer
+	data []byte
+	done bool
+}
+
+func (b *awsChunkedBody) Read(p []byte) (int, error) {
+	for b.buf.Len() == 0 {
+		if b.done {
+			return 0, io.EOF
+		}
+		if err := b.next(); err != nil {
+			return 0, err
+		}
+	}
+	return b.buf.Read(p)
+}
+
+// next encodes the next chunk, the final chunk is empty
+func (b *awsChunkedBody) next() error {
+	if b.data == nil {
+		b.data = make([]byte, b.chunkSize)
+	}
+	n, err := io.ReadFull(b.body, b.data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	chunk := b.data[:n]
+
+	stringToSign := streamingAlgorithm + "\n" + b.amzDate + "\n" + b.scope + "\n" +
+		b.signature + "\n" + sha256Hex(nil) + "\n" + sha256Hex(chunk)
+	b.signature = hex.EncodeToString(hmacSHA256(b.key, stringToSign))
+
+	b.buf.WriteString(strconv.FormatInt(int64(n), 16) + ";chunk-signature=" + b.signature + "\r\n")
+	b.buf.Write(chunk)
+	b.buf.WriteString("\r\n")
+	if n == 0 {
+		b.done = true
+	}
+	return nil
+}
+
+func (b *awsChunkedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,111 @@
+package curl
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger is implemented by *log.Logger
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// SlowLog logs the calls slower than threshold until the response headers
+// (or the error), with the timing breakdown of the last attempt, e.g.
+//
+//	slow request: GET https://example.com/api 1.52s, status 200, attempts 1 (dns 12ms, connect 30ms, tls 61ms, wait 1.41s)
+func SlowLog(threshold time.Duration, logger Logger) Option {
+	return func(r *Request) {
+		r.SlowThreshold = threshold
+		r.Logger = logger
+	}
+}
+
+// timing is the breakdown of an attempt, collected by httptrace
+type timing struct {
+	mu                                             sync.Mutex
+	start, dnsStart, connectStart, tlsStart, wrote time.Time
+	dns, connect, tls, wait                        time.Duration
+	reused                                         bool
+}
+
+func (t *timing) trace() *httptrace.ClientTrace {
+	set := func(f func()) {
+		t.mu.Lock()
+		f()
+		t.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			set(func() {
+				t.start = time.Now()
+				t.dns, t.connect, t.tls, t.wait, t.reused = 0, 0, 0, 0, false
+			})
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			set(func() { t.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			set(func() { t.dns = time.Since(t.dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			set(func() { t.connectStart = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			set(func() { t.connect = time.Since(t.connectStart) })
+		},
+		TLSHandshakeStart: func() {
+			set(func() { t.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			set(func() { t.tls = time.Since(t.tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			set(func() { t.reused = info.Reused })
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			set(func() { t.wrote = time.Now() })
+		},
+		GotFirstResponseByte: func() {
+			set(func() { t.wait = time.Since(t.wrote) })
+		},
+	}
+}
+
+func (t *timing) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.start.IsZero() {
+		return ""
+	}
+	if t.reused {
+		return fmt.Sprintf("reused connection, wait %v", t.wait)
+	}
+	parts := []string{fmt.Sprintf("dns %v", t.dns), fmt.Sprintf("connect %v", t.connect)}
+	if t.tls > 0 {
+		parts = append(parts, fmt.Sprintf("tls %v", t.tls))
+	}
+	parts = append(parts, fmt.Sprintf("wait %v", t.wait))
+	return strings.Join(parts, ", ")
+}
+
+// logSlow logs the call if slower than r.SlowThreshold
+func (r *Request) logSlow(method, url string, elapsed time.Duration, resp *Response, err error, stats *callStats, t *timing) {
+	if r.Logger == nil || elapsed < r.SlowThreshold {
+		return
+	}
+
+	result := "error " + fmt.Sprint(err)
+	if err == nil {
+		result = fmt.Sprintf("status %d", resp.StatusCode)
+	}
+	msg := fmt.Sprintf("slow request: %s %s %v, %s, attempts %d", method, url, elapsed, result, stats.attempts)
+	if breakdown := t.String(); breakdown != "" {
+		msg += " (" + breakdown + ")"
+	}
+	r.Logger.Printf("%s", msg)
+}
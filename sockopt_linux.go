@@ -0,0 +1,36 @@
+//go:build linux
+// +build linux
+
+package curl
+
+import (
+	"syscall"
+)
+
+// SocketMark return a ConnectionOption.DialControl which sets SO_MARK on sockets
+func SocketMark(mark int) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, mark)
+		})
+		if err != nil {
+			return err
+		}
+		return serr
+	}
+}
+
+// BindToDevice return a ConnectionOption.DialControl which binds sockets to the network interface
+func BindToDevice(device string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			serr = syscall.BindToDevice(int(fd), device)
+		})
+		if err != nil {
+			return err
+		}
+		return serr
+	}
+}
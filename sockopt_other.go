@@ -0,0 +1,25 @@
+//go:build !linux
+// +build !linux
+
+package curl
+
+import (
+	"errors"
+	"syscall"
+)
+
+var errSockoptUnsupported = errors.New("socket option is not supported on this platform")
+
+// SocketMark is only supported on linux
+func SocketMark(mark int) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		return errSockoptUnsupported
+	}
+}
+
+// BindToDevice is only supported on linux
+func BindToDevice(device string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		return errSockoptUnsupported
+	}
+}
@@ -0,0 +1,129 @@
+package curl
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SRVTarget resolves a dial address from DNS SRV records, the records are
+// refreshed after RefreshInterval (default 30s).
+//
+//	client, _ := curl.NewClient(&curl.ConnectionOption{
+//		SRVTargets: map[string]*curl.SRVTarget{
+//			"api": curl.NewSRVTarget("http", "tcp", "api.service.consul"),
+//		},
+//	})
+//	resp, err := curl.NewRequest(client).Get("http://api/v1/users")
+type SRVTarget struct {
+	Service         string
+	Proto           string
+	Name            string
+	RefreshInterval time.Duration
+	Resolver        *net.Resolver
+
+	mu      sync.Mutex
+	records []*net.SRV
+	expires time.Time
+}
+
+func NewSRVTarget(service, proto, name string) *SRVTarget {
+	return &SRVTarget{
+		Service: service,
+		Proto:   proto,
+		Name:    name,
+	}
+}
+
+// Next return "host:port" picked by priority and weight
+func (t *SRVTarget) Next(ctx context.Context) (string, error) {
+	records, err := t.lookup(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// lowest priority group
+	group := records[:0:0]
+	for _, r := range records {
+		if len(group) == 0 || r.Priority < group[0].Priority {
+			group = append(group[:0], r)
+		} else if r.Priority == group[0].Priority {
+			group = append(group, r)
+		}
+	}
+
+	// weighted random selection
+	total := 0
+	for _, r := range group {
+		total += int(r.Weight)
+	}
+	picked := group[0]
+	if total > 0 {
+		n := rand.Intn(total)
+		for _, r := range group {
+			if n -= int(r.Weight); n < 0 {
+				picked = r
+				break
+			}
+		}
+	} else {
+		picked = group[rand.Intn(len(group))]
+	}
+
+	host := strings.TrimSuffix(picked.Target, ".")
+	return net.JoinHostPort(host, strconv.Itoa(int(picked.Port))), nil
+}
+
+func (t *SRVTarget) lookup(ctx context.Context) ([]*net.SRV, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.records != nil && time.Now().Before(t.expires) {
+		return t.records, nil
+	}
+
+	resolver := t.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	_, records, err := resolver.LookupSRV(ctx, t.Service, t.Proto, t.Name)
+	if err != nil {
+		// keep serving the stale records if any
+		if t.records != nil {
+			return t.records, nil
+		}
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no SRV records for _%s._%s.%s", t.Service, t.Proto, t.Name)
+	}
+
+	interval := t.RefreshInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	t.records = records
+	t.expires = time.Now().Add(interval)
+	return records, nil
+}
+
+// srvDialContext replaces the address of hosts having a SRVTarget
+func srvDialContext(dial dialFunc, targets map[string]*SRVTarget) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if target, ok := targets[host]; ok {
+			if addr, err = target.Next(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return dial(ctx, network, addr)
+	}
+}
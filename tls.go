@@ -0,0 +1,473 @@
+package curl
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// derivedTransports are the transports derived from the client transport
+// for Request.TLSConfig, ProxyAuth and LocalAddr, they are shared by the
+// requests derived by With
+type derivedTransports struct {
+	mu         sync.Mutex
+	transports map[derivedKey]*http.Transport
+}
+
+type derivedKey struct {
+	base      *http.Transport
+	config    *tls.Config
+	proxyAuth bool
+	local     string // local addresses, the connections are pooled per local address
+}
+
+// maxDerivedTransports bounds the transports kept for distinct configs
+const maxDerivedTransports = 16
+
+// get return the transport of key, derived once from key.base
+func (d *derivedTransports) get(key derivedKey, derive func(*http.Transport)) *http.Transport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if t, ok := d.transports[key]; ok {
+		return t
+	}
+	if d.transports == nil || len(d.transports) >= maxDerivedTransports {
+		for _, t := range d.transports {
+			t.CloseIdleConnections()
+		}
+		d.transports = make(map[derivedKey]*http.Transport)
+	}
+	t := key.base.Clone()
+	derive(t)
+	d.transports[key] = t
+	return t
+}
+
+func (d *derivedTransports) closeIdleConnections() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, t := range d.transports {
+		t.CloseIdleConnections()
+	}
+}
+
+// verifyExceptHosts does the standard chain verification for hosts not in insecureHosts
+func verifyExceptHosts(next func(tls.ConnectionState) error, roots *x509.CertPool, insecureHosts []string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if next != nil {
+			if err := next(cs); err != nil {
+				return err
+			}
+		}
+
+		if containsString(insecureHosts, cs.ServerName) {
+			return nil
+		}
+		_, err := verifyChains(cs, roots)
+		return err
+	}
+}
+
+// verifyChains does the standard chain verification of the peer certificates
+func verifyChains(cs tls.ConnectionState, roots *x509.CertPool) ([][]*x509.Certificate, error) {
+	if len(cs.PeerCertificates) == 0 {
+		return nil, errors.New("no server certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	return cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+}
+
+// PinError is returned when no certificate in the server chain matches a pin
+type PinError struct {
+	Host string
+}
+
+func (e *PinError) Error() string {
+	return "certificate pin mismatch for host: " + e.Host
+}
+
+// verifyPins checks the base64 sha256 pins of SPKI and certificate per host,
+// hosts without pins are not checked. The pins are matched against the
+// verified chains only, the peer certificates are sent by the server and
+// could include any pinned certificate. If the verification is skipped, the
+// chains are verified with roots, a pinned host always requires a valid chain.
+func verifyPins(next func(tls.ConnectionState) error, roots *x509.CertPool, skipVerify bool, publicKeys, certificates map[string][]string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if next != nil {
+			if err := next(cs); err != nil {
+				return err
+			}
+		}
+
+		keyPins, certPins := publicKeys[cs.ServerName], certificates[cs.ServerName]
+		if len(keyPins) == 0 && len(certPins) == 0 {
+			return nil
+		}
+
+		chains := cs.VerifiedChains
+		if len(chains) == 0 && skipVerify {
+			var err error
+			if chains, err = verifyChains(cs, roots); err != nil {
+				return fmt.Errorf("%w: %v", &PinError{Host: cs.ServerName}, err)
+			}
+		}
+		for _, chain := range chains {
+			for _, cert := range chain {
+				keySum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				certSum := sha256.Sum256(cert.Raw)
+				if containsString(keyPins, base64.StdEncoding.EncodeToString(keySum[:])) ||
+					containsString(certPins, base64.StdEncoding.EncodeToString(certSum[:])) {
+					return nil
+				}
+			}
+		}
+		return &PinError{Host: cs.ServerName}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientCertReloader loads a client certificate from PEM files and reloads
+// them when they are modified, used for rotating mTLS certificates.
+type ClientCertReloader struct {
+	CertFile string
+	KeyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func NewClientCertReloader(certFile, keyFile string) (*ClientCertReloader, error) {
+	r := &ClientCertReloader{CertFile: certFile, KeyFile: keyFile}
+	if _, err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetClientCertificate can be used as tls.Config.GetClientCertificate
+func (r *ClientCertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.load()
+}
+
+func (r *ClientCertReloader) load() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTime, err := latestModTime(r.CertFile, r.KeyFile)
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	if r.cert != nil && !modTime.After(r.modTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		// files may be in the middle of rotation, keep the old one
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return r.cert, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, f := range files {
+		fstat, err := os.Stat(f)
+		if err != nil {
+			return latest, err
+		}
+		if fstat.ModTime().After(latest) {
+			latest = fstat.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func newTLSConfig(option *ConnectionOption) (*tls.Config, error) {
+	config := new(tls.Config)
+	if option.TLSConfig != nil {
+		config = option.TLSConfig.Clone()
+	}
+	if option.InsecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+	// versions and ciphers, e.g. tls.VersionTLS12 for compliance
+	if option.TLSMinVersion != 0 {
+		config.MinVersion = option.TLSMinVersion
+	}
+	if option.TLSMaxVersion != 0 {
+		config.MaxVersion = option.TLSMaxVersion
+	}
+	if option.TLSCipherSuites != nil {
+		config.CipherSuites = option.TLSCipherSuites
+	}
+	if option.TLSCurvePreferences != nil {
+		config.CurvePreferences = option.TLSCurvePreferences
+	}
+	if config.MinVersion != 0 && config.MaxVersion != 0 && config.MinVersion > config.MaxVersion {
+		return nil, errors.New("TLSMinVersion is greater than TLSMaxVersion")
+	}
+
+	// NSS key log format, for decrypting traffic in wireshark
+	if option.TLSKeyLogWriter != nil {
+		config.KeyLogWriter = option.TLSKeyLogWriter
+	}
+
+	// custom CA
+	if option.CAFile != "" || option.CADir != "" || option.CAPEM != nil {
+		pool, err := newCertPool(option)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	// client certificate
+	if option.ClientCertFile != "" {
+		reloader, err := NewClientCertReloader(option.ClientCertFile, option.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = reloader.GetClientCertificate
+	} else if option.ClientCertPEM != nil {
+		cert, err := tls.X509KeyPair(option.ClientCertPEM, option.ClientKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+
+	// per host verification is done in VerifyConnection
+	if len(option.InsecureHosts) > 0 && !config.InsecureSkipVerify {
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = verifyExceptHosts(config.VerifyConnection, config.RootCAs, option.InsecureHosts)
+	}
+
+	if len(option.PinnedPublicKeys) > 0 || len(option.PinnedCertificates) > 0 {
+		config.VerifyConnection = verifyPins(config.VerifyConnection, config.RootCAs, config.InsecureSkipVerify, option.PinnedPublicKeys, option.PinnedCertificates)
+	}
+
+	if option.VerifyCertificate != nil {
+		next, verify := config.VerifyConnection, option.VerifyCertificate
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			if next != nil {
+				if err := next(cs); err != nil {
+					return err
+				}
+			}
+			return verify(cs.ServerName, cs.PeerCertificates)
+		}
+	}
+
+	return config, nil
+}
+
+// mergeTLSConfig return a clone of base with the fields set in override, so
+// the pins, roots, client certificates and verifications of the client are
+// kept. The verifications of both configs run.
+func mergeTLSConfig(base, override *tls.Config) *tls.Config {
+	if base == nil {
+		return override.Clone()
+	}
+	config := base.Clone()
+	if override.ServerName != "" {
+		config.ServerName = override.ServerName
+	}
+	if override.RootCAs != nil {
+		config.RootCAs = override.RootCAs
+	}
+	if len(override.Certificates) > 0 {
+		config.Certificates = override.Certificates
+	}
+	if override.GetClientCertificate != nil {
+		config.GetClientCertificate = override.GetClientCertificate
+	}
+	if override.InsecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+	if override.MinVersion != 0 {
+		config.MinVersion = override.MinVersion
+	}
+	if override.MaxVersion != 0 {
+		config.MaxVersion = override.MaxVersion
+	}
+	if len(override.CipherSuites) > 0 {
+		config.CipherSuites = override.CipherSuites
+	}
+	if len(override.CurvePreferences) > 0 {
+		config.CurvePreferences = override.CurvePreferences
+	}
+	if len(override.NextProtos) > 0 {
+		config.NextProtos = override.NextProtos
+	}
+	if override.ClientSessionCache != nil {
+		config.ClientSessionCache = override.ClientSessionCache
+	}
+	if override.SessionTicketsDisabled {
+		config.SessionTicketsDisabled = true
+	}
+	if override.Renegotiation != 0 {
+		config.Renegotiation = override.Renegotiation
+	}
+	if override.KeyLogWriter != nil {
+		config.KeyLogWriter = override.KeyLogWriter
+	}
+	if verify := override.VerifyPeerCertificate; verify != nil {
+		next := config.VerifyPeerCertificate
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+			if next != nil {
+				if err := next(rawCerts, chains); err != nil {
+					return err
+				}
+			}
+			return verify(rawCerts, chains)
+		}
+	}
+	if verify := override.VerifyConnection; verify != nil {
+		next := config.VerifyConnection
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			if next != nil {
+				if err := next(cs); err != nil {
+					return err
+				}
+			}
+			return verify(cs)
+		}
+	}
+	return config
+}
+
+// derivedRoundTripper return the transport derived from base for
+// Request.TLSConfig, ProxyAuth and LocalAddr, it is reused (with its connection
+// pool) between calls.
+func (r *Request) derivedRoundTripper(base http.RoundTripper) (http.RoundTripper, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	bt, ok := base.(*http.Transport)
+	if !ok {
+		switch {
+		case r.TLSConfig != nil:
+			return nil, errors.New("request.TLSConfig requires client.Transport to be *http.Transport")
+		case r.ProxyAuth != nil:
+			return nil, errors.New("request.ProxyAuth requires client.Transport to be *http.Transport")
+		}
+		return nil, errors.New("request.LocalAddr requires client.Transport to be *http.Transport")
+	}
+	var locals []net.IP
+	if r.LocalAddr != "" || r.LocalInterface != "" {
+		var err error
+		if locals, err = localIPs(r.LocalAddr, r.LocalInterface); err != nil {
+			return nil, err
+		}
+	}
+	if r.transports == nil {
+		r.transports = new(derivedTransports)
+	}
+
+	key := derivedKey{
+		base:      bt,
+		config:    r.TLSConfig,
+		proxyAuth: r.ProxyAuth != nil,
+		local:     fmt.Sprint(locals),
+	}
+	t := r.transports.get(key, func(t *http.Transport) {
+		if key.config != nil {
+			t.TLSClientConfig = mergeTLSConfig(t.TLSClientConfig, key.config)
+		}
+		if key.proxyAuth {
+			setProxyAuthHooks(t)
+		}
+		if len(locals) > 0 {
+			setLocalAddr(t, locals)
+		}
+	})
+	if key.proxyAuth {
+		return &proxyAuthTransport{t}, nil
+	}
+	return t, nil
+}
+
+func newCertPool(option *ConnectionOption) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if option.CAAppendSystem {
+		if systemPool, err := x509.SystemCertPool(); err == nil {
+			pool = systemPool
+		}
+	}
+
+	var pems [][]byte
+	if option.CAFile != "" {
+		b, err := ioutil.ReadFile(option.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pems = append(pems, b)
+	}
+	if option.CADir != "" {
+		files, err := ioutil.ReadDir(option.CADir)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			b, err := ioutil.ReadFile(filepath.Join(option.CADir, f.Name()))
+			if err != nil {
+				return nil, err
+			}
+			pems = append(pems, b)
+		}
+	}
+	if option.CAPEM != nil {
+		pems = append(pems, option.CAPEM)
+	}
+
+	added := false
+	for _, b := range pems {
+		if pool.AppendCertsFromPEM(b) {
+			added = true
+		}
+	}
+	if !added {
+		return nil, errors.New("no CA certificates found")
+	}
+	return pool, nil
+}
@@ -0,0 +1,52 @@
+package curl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"testing"
+)
+
+func TestMergeTLSConfig(t *testing.T) {
+	roots := x509.NewCertPool()
+	errPin := errors.New("pin")
+	base := &tls.Config{
+		RootCAs:          roots,
+		MinVersion:       tls.VersionTLS12,
+		VerifyConnection: func(tls.ConnectionState) error { return errPin },
+	}
+	override := &tls.Config{ServerName: "api.example.com", MinVersion: tls.VersionTLS13}
+
+	config := mergeTLSConfig(base, override)
+	if config.ServerName != "api.example.com" || config.MinVersion != tls.VersionTLS13 {
+		t.Errorf("override not applied: %q %x", config.ServerName, config.MinVersion)
+	}
+	if config.RootCAs != roots {
+		t.Error("client roots dropped")
+	}
+	if config.VerifyConnection == nil || config.VerifyConnection(tls.ConnectionState{}) != errPin {
+		t.Error("client verification dropped")
+	}
+	if base.ServerName != "" {
+		t.Error("client config modified")
+	}
+}
+
+func TestMergeTLSConfigChainsVerifications(t *testing.T) {
+	var calls []string
+	base := &tls.Config{VerifyConnection: func(tls.ConnectionState) error {
+		calls = append(calls, "client")
+		return nil
+	}}
+	override := &tls.Config{VerifyConnection: func(tls.ConnectionState) error {
+		calls = append(calls, "request")
+		return nil
+	}}
+
+	if err := mergeTLSConfig(base, override).VerifyConnection(tls.ConnectionState{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || calls[0] != "client" || calls[1] != "request" {
+		t.Fatalf("calls = %v", calls)
+	}
+}
@@ -0,0 +1,127 @@
+package curl
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Token is an access token, e.g. of OAuth2
+type Token struct {
+	Value  string
+	Type   string    // default "Bearer"
+	Expiry time.Time // zero if the token does not expire
+}
+
+// TokenProvider fetches a token, e.g. from a token endpoint. A provider can
+// be used as Request.Auth, wrap it by NewTokenCache to fetch the token once
+// until its expiry.
+type TokenProvider interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenFunc is a TokenProvider function
+type TokenFunc func(ctx context.Context) (*Token, error)
+
+func (f TokenFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// TokenCache keeps the token of Provider until Leeway before its expiry, a
+// single goroutine refreshes it while the others wait for the new token.
+//
+//	req.Auth = curl.NewTokenCache(provider)
+type TokenCache struct {
+	Provider TokenProvider
+	Leeway   time.Duration // default 30s
+
+	mu      sync.Mutex
+	token   *Token
+	refresh *tokenRefresh
+}
+
+// tokenRefresh is the refresh in flight
+type tokenRefresh struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+func NewTokenCache(provider TokenProvider) *TokenCache {
+	return &TokenCache{Provider: provider}
+}
+
+// Token return the cached token, or waits for a refresh
+func (c *TokenCache) Token(ctx context.Context) (*Token, error) {
+	c.mu.Lock()
+	if c.valid(c.token) {
+		token := c.token
+		c.mu.Unlock()
+		return token, nil
+	}
+	refresh := c.refresh
+	if refresh == nil {
+		refresh = &tokenRefresh{done: make(chan struct{})}
+		c.refresh = refresh
+		// the refresh is not canceled with the call which started it
+		go c.fetch(context.WithoutCancel(ctx), refresh)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-refresh.done:
+		return refresh.token, refresh.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *TokenCache) fetch(ctx context.Context, refresh *tokenRefresh) {
+	refresh.token, refresh.err = c.Provider.Token(ctx)
+
+	c.mu.Lock()
+	if refresh.err == nil {
+		c.token = refresh.token
+	}
+	c.refresh = nil
+	c.mu.Unlock()
+	close(refresh.done)
+}
+
+// Invalidate drops the cached token, e.g. after a 401 response
+func (c *TokenCache) Invalidate() {
+	c.mu.Lock()
+	c.token = nil
+	c.mu.Unlock()
+}
+
+func (c *TokenCache) valid(token *Token) bool {
+	if token == nil {
+		return false
+	}
+	if token.Expiry.IsZero() {
+		return true
+	}
+	leeway := c.Leeway
+	if leeway == 0 {
+		leeway = 30 * time.Second
+	}
+	return time.Now().Add(leeway).Before(token.Expiry)
+}
+
+func (c *TokenCache) AuthHeader(ctx context.Context) (string, error) {
+	return tokenAuthHeader(ctx, c)
+}
+
+// tokenAuthHeader return the Authorization value of the token of p
+func tokenAuthHeader(ctx context.Context, p TokenProvider) (string, error) {
+	token, err := p.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	typ := token.Type
+	if typ == "" {
+		typ = "Bearer"
+	}
+	return typ + " " + token.Value, nil
+}
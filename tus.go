@@ -0,0 +1,225 @@
+package curl
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const tusVersion = "1.0.0"
+
+// ErrTusUploadGone is returned when the upload does not exist on the server anymore
+var ErrTusUploadGone = errors.New("tus upload not found")
+
+// ErrTusOffset is returned when the offset of the server is outside the
+// upload, or does not advance after a chunk more than Retries times
+var ErrTusOffset = errors.New("unexpected tus upload offset")
+
+// TusUpload uploads a file to a tus server (https://tus.io), with the
+// creation and checksum extensions. The upload resumes from the offset of
+// the server after a failure, or from URL of a previous upload.
+//
+//	f, _ := os.Open("video.mp4")
+//	fstat, _ := f.Stat()
+//	upload := &curl.TusUpload{Endpoint: "https://tus.example.com/files/", Metadata: map[string]string{"filename": "video.mp4"}}
+//	err := upload.Upload(ctx, f, fstat.Size())
+//	// upload.URL can be saved to resume the upload in another process
+type TusUpload struct {
+	Request    *Request // cloned for the calls, a new request if nil
+	Endpoint   string   // creation URL
+	URL        string   // of the upload, created if empty
+	ChunkSize  int64    // of the PATCH requests, default 4MB
+	Metadata   map[string]string
+	Checksum   string // "sha1", "sha256" or "md5", none if empty
+	Retries    int    // resumes after failed chunks, default 3
+	OnProgress func(offset, size int64)
+}
+
+// Upload sends the size bytes of r, from the offset of the server
+func (t *TusUpload) Upload(ctx context.Context, r io.ReaderAt, size int64) error {
+	offset := int64(0)
+	if t.URL == "" {
+		if err := t.create(ctx, size); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if offset, err = t.offset(ctx, size); err != nil {
+			return err
+		}
+	}
+
+	chunkSize := t.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 4 << 20
+	}
+	retries := t.Retries
+	if retries == 0 {
+		retries = 3
+	}
+
+	buf := make([]byte, chunkSize)
+	failures := 0
+	for offset < size {
+		if t.OnProgress != nil {
+			t.OnProgress(offset, size)
+		}
+		n := chunkSize
+		if size-offset < n {
+			n = size - offset
+		}
+		if _, err := r.ReadAt(buf[:n], offset); err != nil && err != io.EOF {
+			return err
+		}
+
+		next, err := t.patch(ctx, offset, buf[:n])
+		if err == nil {
+			offset, failures = next, 0
+			continue
+		}
+		if ctx.Err() != nil || errors.Is(err, ErrTusUploadGone) || failures >= retries {
+			return err
+		}
+		failures++
+		// resume from the offset received by the server
+		if offset, err = t.offset(ctx, size); err != nil {
+			return err
+		}
+	}
+	if t.OnProgress != nil {
+		t.OnProgress(size, size)
+	}
+	return nil
+}
+
+func (t *TusUpload) request() *Request {
+	r := NewRequest(nil)
+	if t.Request != nil {
+		r = t.Request.clone()
+	}
+	return r.WithHeader("Tus-Resumable", tusVersion)
+}
+
+// create posts the upload creation, it sets URL
+func (t *TusUpload) create(ctx context.Context, size int64) error {
+	r := t.request().WithHeader("Upload-Length", strconv.FormatInt(size, 10))
+	if len(t.Metadata) > 0 {
+		r.WithHeader("Upload-Metadata", tusMetadata(t.Metadata))
+	}
+	resp, err := r.CallContext(ctx, "POST", t.Endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp.discard()
+	if resp.StatusCode != http.StatusCreated {
+		return &StatusError{resp.StatusCode, resp.Status}
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return fmt.Errorf("tus creation without location: %w", err)
+	}
+	t.URL = location.String()
+	return nil
+}
+
+// Offset return the bytes of the upload received by the server
+func (t *TusUpload) Offset(ctx context.Context) (int64, error) {
+	resp, err := t.request().CallContext(ctx, "HEAD", t.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.discard()
+	if err := tusStatus(resp); err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
+
+// offset is Offset checked against the size of the upload
+func (t *TusUpload) offset(ctx context.Context, size int64) (int64, error) {
+	offset, err := t.Offset(ctx)
+	if err == nil && (offset < 0 || offset > size) {
+		return 0, fmt.Errorf("%w: %d of %d bytes", ErrTusOffset, offset, size)
+	}
+	return offset, err
+}
+
+// patch sends the chunk at offset, it return the new offset, an error if
+// the server did not accept a part of the chunk
+func (t *TusUpload) patch(ctx context.Context, offset int64, chunk []byte) (int64, error) {
+	r := t.request().
+		WithHeader("Upload-Offset", strconv.FormatInt(offset, 10)).
+		WithHeader("Content-Type", "application/offset+octet-stream")
+	if t.Checksum != "" {
+		h, err := tusHash(t.Checksum)
+		if err != nil {
+			return 0, err
+		}
+		h.Write(chunk)
+		r.WithHeader("Upload-Checksum", t.Checksum+" "+base64.StdEncoding.EncodeToString(h.Sum(nil)))
+	}
+
+	resp, err := r.CallContext(ctx, "PATCH", t.URL, chunk)
+	if err != nil {
+		return 0, err
+	}
+	resp.discard()
+	if err := tusStatus(resp); err != nil {
+		return 0, err
+	}
+	next, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if next <= offset || next > offset+int64(len(chunk)) {
+		return 0, fmt.Errorf("%w: %d after %d bytes at %d", ErrTusOffset, next, len(chunk), offset)
+	}
+	return next, nil
+}
+
+func tusStatus(resp *Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return fmt.Errorf("%w: %s", ErrTusUploadGone, resp.Request.URL)
+	case resp.StatusCode >= 300:
+		return &StatusError{resp.StatusCode, resp.Status}
+	}
+	return nil
+}
+
+func tusHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "md5":
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported tus checksum algorithm: %s", algorithm)
+}
+
+// tusMetadata encodes the Upload-Metadata header, the values in base64
+func tusMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + " " + base64.StdEncoding.EncodeToString([]byte(metadata[key]))
+	}
+	return strings.Join(pairs, ",")
+}
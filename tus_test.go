@@ -0,0 +1,85 @@
+package curl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// tusServer is a tus server of one upload, which stops advancing the offset
+// once it reaches stuckAt if not 0
+type tusServer struct {
+	mu      sync.Mutex
+	data    []byte
+	stuckAt int
+	patches int
+}
+
+func (s *tusServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.Header.Get("Tus-Resumable") != tusVersion {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	switch req.Method {
+	case "POST":
+		w.Header().Set("Location", "/files/1")
+		w.WriteHeader(http.StatusCreated)
+	case "HEAD":
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.data)))
+	case "PATCH":
+		s.patches++
+		if req.Header.Get("Upload-Offset") != strconv.Itoa(len(s.data)) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		chunk, _ := ioutil.ReadAll(req.Body)
+		if s.stuckAt == 0 || len(s.data) < s.stuckAt {
+			s.data = append(s.data, chunk...)
+		}
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.data)))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestTusUpload(t *testing.T) {
+	s := new(tusServer)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	upload := &TusUpload{Endpoint: srv.URL + "/files/", ChunkSize: 300, Checksum: "sha256"}
+	if err := upload.Upload(context.Background(), bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(s.data, content) || s.patches != 4 {
+		t.Fatalf("received %d bytes in %d patches", len(s.data), s.patches)
+	}
+	if upload.URL != srv.URL+"/files/1" {
+		t.Fatalf("URL = %s", upload.URL)
+	}
+}
+
+func TestTusUploadStuckOffset(t *testing.T) {
+	s := &tusServer{stuckAt: 300}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	upload := &TusUpload{Endpoint: srv.URL + "/files/", ChunkSize: 300, Retries: 2}
+	err := upload.Upload(context.Background(), bytes.NewReader(content), int64(len(content)))
+	if !errors.Is(err, ErrTusOffset) {
+		t.Fatalf("err = %v, want ErrTusOffset", err)
+	}
+	// the first chunk, then the second chunk and its 2 retries
+	if s.patches != 4 {
+		t.Fatalf("%d patches, want 4", s.patches)
+	}
+}
@@ -0,0 +1,90 @@
+package curl
+
+import (
+	"net/url"
+	"strings"
+)
+
+// URLBuilder builds URLs escaping each path segment and query value.
+//
+//	u, _ := curl.NewURLBuilder("https://api.example.com/v1")
+//	u.Path("users", name, "repos").Query("page", "2").String()
+//	// https://api.example.com/v1/users/a%2Fb/repos?page=2
+type URLBuilder struct {
+	base     url.URL
+	segments []string
+	query    url.Values
+}
+
+// NewURLBuilder return a builder starting from base, which can be ""
+func NewURLBuilder(base string) (*URLBuilder, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	return &URLBuilder{base: *u, query: u.Query()}, nil
+}
+
+// Scheme sets the scheme, e.g. "https"
+func (b *URLBuilder) Scheme(scheme string) *URLBuilder {
+	b.base.Scheme = scheme
+	return b
+}
+
+// Host sets the host, with an optional ":port"
+func (b *URLBuilder) Host(host string) *URLBuilder {
+	b.base.Host = host
+	return b
+}
+
+// Path appends segments to the path, "/" in a segment is escaped
+func (b *URLBuilder) Path(segments ...string) *URLBuilder {
+	b.segments = append(b.segments, segments...)
+	return b
+}
+
+// Query adds the values of a query parameter
+func (b *URLBuilder) Query(key string, values ...string) *URLBuilder {
+	for _, v := range values {
+		b.query.Add(key, v)
+	}
+	return b
+}
+
+// SetQuery replaces the values of a query parameter
+func (b *URLBuilder) SetQuery(key string, values ...string) *URLBuilder {
+	b.query[key] = append([]string(nil), values...)
+	return b
+}
+
+// DelQuery removes a query parameter
+func (b *URLBuilder) DelQuery(key string) *URLBuilder {
+	b.query.Del(key)
+	return b
+}
+
+// Fragment sets the fragment, without "#"
+func (b *URLBuilder) Fragment(fragment string) *URLBuilder {
+	b.base.Fragment = fragment
+	b.base.RawFragment = ""
+	return b
+}
+
+// URL return the built URL
+func (b *URLBuilder) URL() *url.URL {
+	u := b.base
+	if len(b.segments) > 0 {
+		raw := strings.TrimSuffix(u.EscapedPath(), "/")
+		for _, segment := range b.segments {
+			raw += "/" + url.PathEscape(segment)
+		}
+		u.Path, _ = url.PathUnescape(raw)
+		u.RawPath = raw
+	}
+	u.RawQuery = b.query.Encode()
+	return &u
+}
+
+func (b *URLBuilder) String() string {
+	return b.URL().String()
+}
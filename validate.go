@@ -0,0 +1,132 @@
+package curl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Validator checks a prepared request before it is sent, an error rejects the call
+type Validator func(req *http.Request) error
+
+// ErrValidation is wrapped by the ValidationError of rejected calls
+var ErrValidation = errors.New("request rejected")
+
+// ValidationError is returned when a validator rejects a call
+type ValidationError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	return ErrValidation.Error() + ": " + e.Method + " " + e.URL + ": " + e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return []error{ErrValidation, e.Err}
+}
+
+// WithValidators adds validators run in order before every call and every
+// redirect it follows
+//
+//	req := curl.NewRequest(client).WithValidators(
+//		curl.RequireHeader("Authorization"),
+//		curl.MaxBodySize(10<<20),
+//		curl.AllowHosts("api.example.com", "*.internal.example.com"),
+//	)
+func (r *Request) WithValidators(validators ...Validator) *Request {
+	r.Validators = append(r.Validators, validators...)
+	return r
+}
+
+// validate runs r.Validators on req
+func (r *Request) validate(req *http.Request) error {
+	for _, validator := range r.Validators {
+		if err := validator(req); err != nil {
+			return &ValidationError{req.Method, r.redaction().URL(req.URL), err}
+		}
+	}
+	return nil
+}
+
+// validateRedirect runs r.Validators on the redirects accepted by next, once
+// the redirect policy stripped the headers or upgraded the scheme
+func (r *Request) validateRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	if next == nil {
+		next = checkRedirect(10)
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if err := next(req, via); err != nil {
+			return err
+		}
+		return r.validate(req)
+	}
+}
+
+// RequireHeader rejects the requests without the header
+func RequireHeader(name string) Validator {
+	return func(req *http.Request) error {
+		if req.Header.Get(name) == "" {
+			return fmt.Errorf("missing header %s", http.CanonicalHeaderKey(name))
+		}
+		return nil
+	}
+}
+
+// MaxBodySize rejects the requests with a body over max bytes, a body of
+// unknown length fails while sent once over max
+func MaxBodySize(max int64) Validator {
+	return func(req *http.Request) error {
+		if req.ContentLength > max {
+			return fmt.Errorf("body of %d bytes exceeds %d bytes", req.ContentLength, max)
+		}
+		if req.ContentLength < 0 || (req.ContentLength == 0 && req.Body != nil && req.Body != http.NoBody) {
+			req.Body = &maxBody{req.Body, max}
+			if getBody := req.GetBody; getBody != nil {
+				req.GetBody = func() (io.ReadCloser, error) {
+					body, err := getBody()
+					if err != nil {
+						return nil, err
+					}
+					return &maxBody{body, max}, nil
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// maxBody fails once more than max bytes are read
+type maxBody struct {
+	io.ReadCloser
+	max int64
+}
+
+func (b *maxBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.max -= int64(n); b.max < 0 {
+		return n, errors.New("body exceeds the maximum size")
+	}
+	return n, err
+}
+
+// AllowHosts rejects the requests to the hosts not matching the patterns,
+// where "*" matches any characters, e.g. "*.example.com". The relative
+// urls of Endpoints are not checked, the redirects are.
+func AllowHosts(patterns ...string) Validator {
+	return func(req *http.Request) error {
+		if !req.URL.IsAbs() {
+			return nil
+		}
+		host := strings.ToLower(req.URL.Hostname())
+		for _, pattern := range patterns {
+			if matchWildcard(strings.ToLower(pattern), host) {
+				return nil
+			}
+		}
+		return fmt.Errorf("host %s is not allowed", host)
+	}
+}
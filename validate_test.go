@@ -0,0 +1,41 @@
+package curl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAllowHostsRedirect(t *testing.T) {
+	var reached bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		reached = true
+	}))
+	defer target.Close()
+	// the same server by another host name
+	elsewhere := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, elsewhere+"/next", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	_, err := NewRequest(nil).WithValidators(AllowHosts("127.0.0.1")).Get(srv.URL)
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("err = %v, want ErrValidation", err)
+	}
+	if reached {
+		t.Fatal("redirect to a host not allowed was followed")
+	}
+
+	resp, err := NewRequest(nil).WithValidators(AllowHosts("127.0.0.1", "localhost")).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.discard()
+	if !reached {
+		t.Fatal("redirect to an allowed host not followed")
+	}
+}
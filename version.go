@@ -0,0 +1,4 @@
+package curl
+
+// Version of the package, sent in DefaultUserAgent
+const Version = "0.1.0"
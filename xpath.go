@@ -0,0 +1,318 @@
+package curl
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XMLNodeType is the type of a XMLNode
+type XMLNodeType int
+
+const (
+	XMLDocumentNode XMLNodeType = iota
+	XMLElementNode
+	XMLTextNode
+	XMLAttributeNode
+)
+
+// XMLNode is a node of a parsed XML document, names are matched by local name
+type XMLNode struct {
+	Type     XMLNodeType
+	Name     xml.Name
+	Attr     []xml.Attr
+	Data     string // text of text and attribute nodes
+	Parent   *XMLNode
+	Children []*XMLNode
+}
+
+// XML parses the Response Body as a XML document
+func (resp *Response) XML() (*XMLNode, error) {
+	b, err := resp.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return ParseXML(bytes.NewReader(b))
+}
+
+// ParseXML parses a XML document in a tree of nodes
+func ParseXML(r io.Reader) (*XMLNode, error) {
+	doc := &XMLNode{Type: XMLDocumentNode}
+	node := doc
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			child := &XMLNode{Type: XMLElementNode, Name: t.Name, Attr: t.Copy().Attr, Parent: node}
+			node.Children = append(node.Children, child)
+			node = child
+		case xml.EndElement:
+			node = node.Parent
+		case xml.CharData:
+			if node != doc {
+				node.Children = append(node.Children, &XMLNode{Type: XMLTextNode, Data: string(t), Parent: node})
+			}
+		}
+	}
+	if node != doc {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return doc, nil
+}
+
+// Text return the text content of the node and its descendants
+func (n *XMLNode) Text() string {
+	if n.Type == XMLTextNode || n.Type == XMLAttributeNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for _, c := range n.Children {
+		sb.WriteString(c.Text())
+	}
+	return sb.String()
+}
+
+// AttrValue return the value of an attribute by local name, "" if not present
+func (n *XMLNode) AttrValue(name string) string {
+	for _, attr := range n.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Find return the nodes selected by a XPath expression relative to n.
+// Supported is an abbreviated location path subset: / and // steps, element
+// names (namespace prefixes are ignored), *, ., .., @attr, text() and the
+// predicates [n], [last()], [@attr], [@attr='v'], [name] and [name='v'].
+//
+//	ids, err := doc.Find("//order[@status='open']/item[1]/@id")
+func (n *XMLNode) Find(expr string) ([]*XMLNode, error) {
+	steps, err := parseXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []*XMLNode{n}
+	if strings.HasPrefix(expr, "/") {
+		root := n
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		nodes = []*XMLNode{root}
+	}
+	for _, step := range steps {
+		var next []*XMLNode
+		seen := make(map[*XMLNode]bool)
+		for _, node := range nodes {
+			contexts := []*XMLNode{node}
+			if step.descendant {
+				contexts = node.descendantsOrSelf(nil)
+			}
+			for _, context := range contexts {
+				for _, selected := range step.selectFrom(context) {
+					if !seen[selected] {
+						seen[selected] = true
+						next = append(next, selected)
+					}
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+// First return the first node selected by expr, nil if none, see Find
+func (n *XMLNode) First(expr string) (*XMLNode, error) {
+	nodes, err := n.Find(expr)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// FindString return the text of the first node selected by expr, "" if none
+func (n *XMLNode) FindString(expr string) (string, error) {
+	node, err := n.First(expr)
+	if err != nil || node == nil {
+		return "", err
+	}
+	return node.Text(), nil
+}
+
+func (n *XMLNode) descendantsOrSelf(list []*XMLNode) []*XMLNode {
+	list = append(list, n)
+	for _, c := range n.Children {
+		if c.Type == XMLElementNode {
+			list = c.descendantsOrSelf(list)
+		}
+	}
+	return list
+}
+
+type xpathStep struct {
+	descendant bool   // preceded by //
+	test       string // name, *, ., .., @name, @*, text()
+	predicates []string
+}
+
+func (s *xpathStep) selectFrom(node *XMLNode) []*XMLNode {
+	var nodes []*XMLNode
+	switch {
+	case s.test == ".":
+		nodes = []*XMLNode{node}
+	case s.test == "..":
+		if node.Parent != nil {
+			nodes = []*XMLNode{node.Parent}
+		}
+	case s.test == "text()":
+		for _, c := range node.Children {
+			if c.Type == XMLTextNode {
+				nodes = append(nodes, c)
+			}
+		}
+	case strings.HasPrefix(s.test, "@"):
+		name := s.test[1:]
+		for _, attr := range node.Attr {
+			if name == "*" || attr.Name.Local == name {
+				nodes = append(nodes, &XMLNode{Type: XMLAttributeNode, Name: attr.Name, Data: attr.Value, Parent: node})
+			}
+		}
+	default:
+		for _, c := range node.Children {
+			if c.Type == XMLElementNode && (s.test == "*" || c.Name.Local == s.test) {
+				nodes = append(nodes, c)
+			}
+		}
+	}
+
+	for _, predicate := range s.predicates {
+		nodes = filterXPath(nodes, predicate)
+	}
+	return nodes
+}
+
+func filterXPath(nodes []*XMLNode, predicate string) []*XMLNode {
+	if predicate == "last()" {
+		if len(nodes) == 0 {
+			return nil
+		}
+		return nodes[len(nodes)-1:]
+	}
+	if i, err := strconv.Atoi(predicate); err == nil {
+		if i < 1 || i > len(nodes) {
+			return nil
+		}
+		return nodes[i-1 : i]
+	}
+
+	name, value, compare := strings.Cut(predicate, "=")
+	name = strings.TrimSpace(name)
+	value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+	var filtered []*XMLNode
+	for _, node := range nodes {
+		step := &xpathStep{test: localName(name)}
+		for _, selected := range step.selectFrom(node) {
+			if !compare || selected.Text() == value {
+				filtered = append(filtered, node)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func parseXPath(expr string) ([]*xpathStep, error) {
+	invalid := errors.New("invalid xpath: " + expr)
+	if strings.TrimSpace(expr) == "" {
+		return nil, invalid
+	}
+	if expr == "/" {
+		return nil, nil
+	}
+
+	var steps []*xpathStep
+	descendant := false
+	for i, part := range splitXPath(strings.TrimPrefix(expr, "/")) {
+		if part == "" {
+			// "//" is an empty step, not allowed at the end
+			if descendant || i == 0 && !strings.HasPrefix(expr, "//") {
+				return nil, invalid
+			}
+			descendant = true
+			continue
+		}
+
+		step := &xpathStep{descendant: descendant}
+		descendant = false
+		test := part
+		if j := strings.IndexByte(part, '['); j >= 0 {
+			test = part[:j]
+			for rest := part[j:]; rest != ""; {
+				end := strings.IndexByte(rest, ']')
+				if rest[0] != '[' || end < 0 {
+					return nil, invalid
+				}
+				step.predicates = append(step.predicates, strings.TrimSpace(rest[1:end]))
+				rest = rest[end+1:]
+			}
+		}
+		if test == "" {
+			return nil, invalid
+		}
+		step.test = localName(test)
+		steps = append(steps, step)
+	}
+	if descendant || len(steps) == 0 {
+		return nil, invalid
+	}
+	return steps, nil
+}
+
+// splitXPath splits expr by the slashes outside of predicates
+func splitXPath(expr string) []string {
+	var parts []string
+	depth, quote, start := 0, byte(0), 0
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '/' && depth == 0:
+			parts = append(parts, expr[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, expr[start:])
+}
+
+// localName strips the namespace prefix of a name test
+func localName(test string) string {
+	if i := strings.IndexByte(test, ':'); i >= 0 {
+		if strings.HasPrefix(test, "@") {
+			return "@" + test[i+1:]
+		}
+		return test[i+1:]
+	}
+	return test
+}